@@ -0,0 +1,53 @@
+package main
+
+import (
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/logger"
+	"go_services/pkg/transport"
+)
+
+// Message types of the published alerts and review items
+const (
+	alertMessageType  = "alert"
+	reviewMessageType = "review"
+)
+
+// queueAlertPublisher publishes alerts to a queue or topic, falling back to logging them
+// when no alert queue is configured.
+type queueAlertPublisher struct {
+	publisher transport.Publisher
+	queueName string
+}
+
+func (p *queueAlertPublisher) PublishAlert(alert models.Alert) error {
+	if p.queueName == "" {
+		logger.Log.Warn().Str("type", alert.Type).Str("vehicle_plate", alert.VehiclePlate).Msg(alert.Detail)
+		return nil
+	}
+	return p.publisher.Publish(p.queueName, alert.VehiclePlate, alertMessageType, alert)
+}
+
+// queueReviewPublisher publishes events that need a manual decision to the review queue, falling back
+// to logging them when no review queue is configured.
+type queueReviewPublisher struct {
+	publisher transport.Publisher
+	queueName string
+}
+
+func (p *queueReviewPublisher) PublishReview(item models.ReviewItem) error {
+	if p.queueName == "" {
+		logger.Log.Warn().Str("reason", item.Reason).Str("vehicle_plate", item.VehiclePlate()).Msg("Event needs review")
+		return nil
+	}
+	return p.publisher.Publish(p.queueName, item.VehiclePlate(), reviewMessageType, item)
+}
+
+// discardSink drops the alerts, review items and orphan exits of replayed events; they were raised when
+// the events were first processed.
+type discardSink struct{}
+
+func (discardSink) PublishAlert(models.Alert) error { return nil }
+
+func (discardSink) PublishReview(models.ReviewItem) error { return nil }
+
+func (discardSink) RecordOrphanExit(models.ExitEvent) error { return nil }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/transport/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueReviewPublisher(t *testing.T) {
+	at := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		item        models.ReviewItem
+		expectedKey string
+	}{
+		{
+			name:        "Entry",
+			item:        models.ReviewItem{Reason: models.ReviewReasonLowReadConfidence, EntryEvent: &models.EntryEvent{VehiclePlate: "ABC123", EntryDateTime: at}},
+			expectedKey: "ABC123",
+		},
+		{
+			name:        "Exit",
+			item:        models.ReviewItem{Reason: models.ReviewReasonLowReadConfidence, ExitEvent: &models.ExitEvent{VehiclePlate: "XYZ789", ExitDateTime: at}},
+			expectedKey: "XYZ789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := memory.NewBroker()
+			publisher := &queueReviewPublisher{publisher: broker, queueName: "parking_reviews"}
+
+			assert.NoError(t, publisher.PublishReview(tt.item))
+
+			published := broker.Published("parking_reviews")
+			assert.Len(t, published, 1)
+			if len(published) == 1 {
+				assert.Equal(t, tt.expectedKey, published[0].Key)
+				assert.Equal(t, reviewMessageType, published[0].Type)
+			}
+		})
+	}
+
+	// without a review queue the item is logged
+	publisher := &queueReviewPublisher{publisher: memory.NewBroker()}
+	assert.NoError(t, publisher.PublishReview(tests[0].item))
+}
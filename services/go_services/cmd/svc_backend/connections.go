@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/config"
+	"go_services/pkg/kafka"
+	"go_services/pkg/logger"
+	"go_services/pkg/rabbitmq"
+	"go_services/pkg/retry"
+	"go_services/pkg/tlsconfig"
+	"go_services/pkg/transport"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Event queue modes in which entries and exits share one queue or stream
+const (
+	singleEventQueue = "single"
+	streamEventQueue = "stream"
+)
+
+const kafkaTransport = "kafka"
+
+// messaging is the connection to the configured message broker.
+type messaging struct {
+	consumer  transport.Consumer
+	publisher transport.Publisher
+	rabbitMQ  *rabbitmq.RabbitMQClient // nil with Kafka; consumes the event stream
+	close     func()
+
+	// setProcessRetry replaces the retry policy of events that fail to process; nil with RabbitMQ,
+	// which hands failed events to the dead letter exchange
+	setProcessRetry func(policy retry.Policy)
+}
+
+// openMessaging connects to the configured message broker. With RabbitMQ, the queues are declared
+// first when enabled and alerts and reviews are published on long-lived channels with confirms.
+func openMessaging(cfg *config.Config) (*messaging, error) {
+	if cfg.Transport == kafkaTransport {
+		brokers := strings.Split(cfg.KafkaBrokers, ",")
+		consumer := kafka.NewConsumer(brokers, cfg.KafkaGroupID, cfg.KafkaDeadLetterTopic, cfg.KafkaProcessRetry())
+		publisher := kafka.NewPublisher(brokers)
+		logger.Log.Info().Msgf("Using Kafka brokers %s", cfg.KafkaBrokers)
+		return &messaging{
+			consumer:  consumer,
+			publisher: publisher,
+			close: func() {
+				consumer.Close()
+				publisher.Close()
+			},
+			setProcessRetry: consumer.SetRetryPolicy,
+		}, nil
+	}
+
+	rabbitMQTLSConfig, err := tlsconfig.LoadConfigured(cfg.RabbitMQTLSFiles())
+	if err != nil {
+		return nil, fmt.Errorf("error loading RabbitMQ TLS configuration: %v", err)
+	}
+	rabbitMQClient, err := rabbitmq.Connect(cfg.RabbitMQURL, rabbitMQTLSConfig, cfg.RabbitMQConnectRetry())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RabbitMQDeclareTopology {
+		if err := rabbitMQClient.DeclareTopology(rabbitMQTopology(cfg)); err != nil {
+			rabbitMQClient.Close()
+			return nil, err
+		}
+	}
+	publisher := rabbitMQClient.NewPublisher(rabbitmq.DefaultPublisherChannels)
+	return &messaging{
+		consumer:  rabbitMQClient,
+		publisher: &rabbitmq.ExchangePublisher{Publisher: publisher},
+		rabbitMQ:  rabbitMQClient,
+		close: func() {
+			publisher.Close()
+			rabbitMQClient.Close()
+		},
+	}, nil
+}
+
+// rabbitMQTopology returns the queues the service consumes and publishes to, with the configured
+// queue arguments and dead letter exchange, and the bindings of the event queues to the topic exchange.
+func rabbitMQTopology(cfg *config.Config) rabbitmq.Topology {
+	options := rabbitmq.QueueOptions{
+		Type:               cfg.RabbitMQQueueType,
+		MessageTTL:         cfg.RabbitMQMessageTTL,
+		MaxLength:          cfg.RabbitMQMaxLength,
+		DeadLetterExchange: cfg.RabbitMQDeadLetterExchange,
+	}
+	entryQueue, exitQueue := cfg.EntryQueueName, cfg.ExitQueueName
+	if cfg.EventQueueMode != "split" {
+		entryQueue, exitQueue = cfg.EventQueueName, cfg.EventQueueName
+	}
+	queues := []string{cfg.ValidationQueueName, cfg.AlertQueueName, cfg.ReviewQueueName}
+	if cfg.EventQueueMode != streamEventQueue {
+		queues = append([]string{entryQueue, exitQueue}, queues...)
+	}
+	topology := rabbitmq.NewTopology(queues, options, cfg.RabbitMQDeadLetterQueue)
+	if cfg.EventQueueMode == streamEventQueue {
+		// the stream keeps the events for replays, limited by age rather than by the queue arguments
+		topology.Queues = append(topology.Queues, rabbitmq.Queue{
+			Name:    cfg.EventQueueName,
+			Options: rabbitmq.QueueOptions{Type: rabbitmq.StreamQueue, MaxAge: cfg.StreamMaxAge},
+		})
+	}
+	if cfg.Exchange == "" {
+		return topology
+	}
+	topology.Exchanges = append(topology.Exchanges, rabbitmq.Exchange{Name: cfg.Exchange, Kind: amqp091.ExchangeTopic})
+	topology.Bindings = append(topology.Bindings,
+		rabbitmq.Binding{Queue: entryQueue, Exchange: cfg.Exchange, RoutingKey: cfg.EntryBindingKey},
+		rabbitmq.Binding{Queue: exitQueue, Exchange: cfg.Exchange, RoutingKey: cfg.ExitBindingKey},
+	)
+	return topology
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/sessions"
+	generatorconfig "go_services/cmd/svc_generator/config"
+	"go_services/cmd/svc_generator/event"
+	"go_services/pkg/logger"
+	"go_services/pkg/memstore"
+	"go_services/pkg/transport"
+	"go_services/pkg/transport/memory"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parkingLogSink collects the parking logs the exit processor posts.
+type parkingLogSink struct {
+	mu   sync.Mutex
+	logs []models.ParkingLog
+}
+
+func (s *parkingLogSink) PostSummary(data interface{}) error {
+	if log, ok := data.(models.ParkingLog); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.logs = append(s.logs, log)
+	}
+	return nil
+}
+
+// loadGeneratorConfig loads the configuration of a generator with the settings in content.
+func loadGeneratorConfig(t *testing.T, content string) *generatorconfig.Config {
+	file := filepath.Join(t.TempDir(), "generator.yaml")
+	content = "rabbitmq_url: amqp://rabbitmq\nredis_password: \"\"\n" + content
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	cfg, _, err := generatorconfig.LoadConfig([]string{"--config", file})
+	assert.NoError(t, err)
+	return cfg
+}
+
+// TestEndToEnd publishes generated events the way the generators route them through the in-memory
+// transport to the backend's processors and checks the parking logs they produce.
+func TestEndToEnd(t *testing.T) {
+	for _, key := range []string{"CONFIG_FILE", "RABBITMQ_URL", "TRANSPORT", "EVENT_QUEUE_MODE", "RABBITMQ_EVENT_QUEUE_NAME", "TARIFF_HOURLY_RATE_CENTS", "DUPLICATE_ENTRY_POLICY", "PLATE_RULES_FILE", "OVERSTAY_THRESHOLD",
+		"RABBITMQ_EXCHANGE", "RABBITMQ_QUEUE_NAME", "FACILITY", "GENERATOR_MODE", "REDIS_PASSWORD"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	defer logger.SetLevel("info")
+
+	tests := []struct {
+		name           string
+		config         string
+		entryGenerator string
+		exitGenerator  string
+	}{
+		{
+			name:           "Split Queues",
+			config:         "rabbitmq_entry_queue_name: vehicle_entries\nrabbitmq_exit_queue_name: vehicle_exits\n",
+			entryGenerator: "rabbitmq_exchange: \"\"\nrabbitmq_queue_name: vehicle_entries\n",
+			exitGenerator:  "rabbitmq_exchange: \"\"\nrabbitmq_queue_name: vehicle_exits\n",
+		},
+		{
+			name:           "Single Queue",
+			config:         "event_queue_mode: single\nrabbitmq_event_queue_name: vehicle_events\n",
+			entryGenerator: "rabbitmq_exchange: \"\"\nrabbitmq_queue_name: vehicle_events\n",
+			exitGenerator:  "rabbitmq_exchange: \"\"\nrabbitmq_queue_name: vehicle_events\n",
+		},
+		{
+			name:           "Exchange",
+			config:         "rabbitmq_entry_queue_name: vehicle_entries\nrabbitmq_exit_queue_name: vehicle_exits\n",
+			entryGenerator: "facility: north\n",
+			exitGenerator:  "facility: south\n",
+		},
+		{
+			name:           "Exchange To Single Queue",
+			config:         "event_queue_mode: single\nrabbitmq_event_queue_name: vehicle_events\n",
+			entryGenerator: "facility: north\n",
+			exitGenerator:  "facility: north\n",
+		},
+		{
+			name:           "Kafka",
+			config:         "transport: kafka\nrabbitmq_entry_queue_name: vehicle_entries\nrabbitmq_exit_queue_name: vehicle_exits\n",
+			entryGenerator: "transport: kafka\nrabbitmq_queue_name: vehicle_entries\n",
+			exitGenerator:  "transport: kafka\nrabbitmq_queue_name: vehicle_exits\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "backend.yaml")
+			content := "rabbitmq_url: amqp://rabbitmq\nstore_backend: memory\ntariff_hourly_rate_cents: 200\nrabbitmq_alert_queue_name: parking_alerts\n" + tt.config
+			assert.NoError(t, os.WriteFile(file, []byte(content), 0600))
+			cfg, _, err := config.LoadConfig([]string{"--config", file})
+			assert.NoError(t, err)
+			entryGenerator := loadGeneratorConfig(t, tt.entryGenerator)
+			exitGenerator := loadGeneratorConfig(t, tt.exitGenerator)
+
+			// the queues are bound to the exchange as the backend declares them
+			broker := memory.NewBroker()
+			if cfg.Transport != kafkaTransport {
+				for _, binding := range rabbitMQTopology(cfg).Bindings {
+					broker.Bind(binding.Queue, binding.RoutingKey)
+				}
+			}
+			sink := &parkingLogSink{}
+			store := memstore.New()
+			sessionStore := sessions.NewMemoryStore()
+			svc := &service{
+				build: func(cfg *config.Config) (*pipeline, error) {
+					p, err := buildPipeline(cfg, broker, store, sessionStore)
+					if err != nil {
+						return nil, err
+					}
+					p.exit.SummaryPoster = sink
+					return p, nil
+				},
+			}
+			assert.NoError(t, svc.apply(cfg))
+			assert.NoError(t, setupEventProcessors(cfg, &messaging{consumer: broker, publisher: broker}, svc, nil))
+
+			// events published by the generators, at fixed times
+			base := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+			publishEntry := func(plate string, at time.Duration) {
+				payload := event.GenerateEntryEvent(event.Camera{})
+				payload.VehiclePlate, payload.EntryDateTime = plate, base.Add(at)
+				assert.NoError(t, event.Publish(broker, entryGenerator, transport.EntryEvent, plate, payload))
+			}
+			publishExit := func(plate string, at time.Duration) {
+				payload := event.GenerateExitEvent(event.Camera{})
+				payload.VehiclePlate, payload.ExitDateTime = plate, base.Add(at)
+				assert.NoError(t, event.Publish(broker, exitGenerator, transport.ExitEvent, plate, payload))
+			}
+
+			publishEntry("plate-101", 0)
+			publishEntry("plate-202", 15*time.Minute)
+			publishEntry("plate-101", 5*time.Minute) // duplicate, the latest entry is kept
+			publishExit("plate-101", time.Hour)
+			publishExit("plate-999", time.Hour) // no entry
+			publishExit("plate-202", 2*time.Hour+30*time.Minute)
+
+			assert.Len(t, sink.logs, 2)
+			if len(sink.logs) == 2 {
+				assert.Equal(t, "PLATE101", sink.logs[0].VehiclePlate)
+				assert.Equal(t, "plate-101", sink.logs[0].RawVehiclePlate)
+				assert.Equal(t, base.Add(5*time.Minute), sink.logs[0].EntryDateTime)
+				assert.Equal(t, "55m0s", sink.logs[0].Duration)
+				assert.Equal(t, int64(200), sink.logs[0].FeeCents)
+
+				assert.Equal(t, "PLATE202", sink.logs[1].VehiclePlate)
+				assert.Equal(t, "2h15m0s", sink.logs[1].Duration)
+				assert.Equal(t, int64(600), sink.logs[1].FeeCents)
+			}
+
+			alerts := broker.Published("parking_alerts")
+			assert.Len(t, alerts, 1)
+			if len(alerts) == 1 {
+				assert.Equal(t, "PLATE101", alerts[0].Key)
+				assert.Contains(t, string(alerts[0].Body), models.AlertTypeDuplicateEntry)
+			}
+			open, err := sessionStore.ListOpen()
+			assert.NoError(t, err)
+			assert.Empty(t, open)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package invoicing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileSink writes each invoice to Dir as <number>.json, <number>.txt and <number>.html.
+type FileSink struct {
+	Dir string
+}
+
+// PostSummary writes the invoice in all formats.
+func (s *FileSink) PostSummary(data interface{}) error {
+	invoice, ok := data.(Invoice)
+	if !ok {
+		return fmt.Errorf("file sink cannot write %T", data)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating invoice directory: %v", err)
+	}
+
+	base := filepath.Join(s.Dir, invoice.Number)
+	if err := writeFile(base+".json", func(f *os.File) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(invoice)
+	}); err != nil {
+		return err
+	}
+	if err := writeFile(base+".txt", func(f *os.File) error { return RenderText(f, invoice) }); err != nil {
+		return err
+	}
+	return writeFile(base+".html", func(f *os.File) error { return RenderHTML(f, invoice) })
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", path, err)
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
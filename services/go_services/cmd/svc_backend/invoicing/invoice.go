@@ -0,0 +1,161 @@
+package invoicing
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/logger"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SequenceKey is the Redis key holding the last issued invoice number.
+const SequenceKey = "invoice_sequence"
+
+// NumbersKeyPrefix prefixes the hash of a plate that maps the entry times of its invoiced sessions
+// to their invoice numbers.
+const NumbersKeyPrefix = "invoice_numbers:"
+
+// LineItem is one line of an invoice; discounts have negative amounts.
+type LineItem struct {
+	Description string `json:"description"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+// Invoice is the billing document of a completed parking session.
+type Invoice struct {
+	Number         string     `json:"number"`
+	IssuedAt       time.Time  `json:"issued_at"`
+	VehiclePlate   string     `json:"vehicle_plate"`
+	EntryDateTime  time.Time  `json:"entry_date_time"`
+	ExitDateTime   time.Time  `json:"exit_date_time"`
+	Lines          []LineItem `json:"lines"`
+	SubtotalCents  int64      `json:"subtotal_cents"`
+	TaxRatePercent float64    `json:"tax_rate_percent"`
+	TaxCents       int64      `json:"tax_cents"`
+	TotalCents     int64      `json:"total_cents"`
+	Currency       string     `json:"currency"`
+}
+
+// NumberStore defines the interface for issuing sequential invoice numbers and keeping the number
+// of each invoiced session.
+type NumberStore interface {
+	GetCounter(key string) (int64, error)
+	Increment(key string) (int64, error)
+	AddFieldsToHash(hashKey string, fields map[string]string) error
+	GetAllFields(hashKey string) (map[string]string, error)
+}
+
+// Sink defines the interface invoices are emitted through; it matches processors.SummaryPoster
+// so the same implementations can be used for summaries and invoices.
+type Sink interface {
+	PostSummary(data interface{}) error
+}
+
+// Invoicer turns completed parking sessions into invoices. It implements processors.SummaryPoster
+// so it can be added next to the other summary posters.
+type Invoicer struct {
+	Numbers        NumberStore
+	Sink           Sink
+	TaxRatePercent float64 // added on top of the tariff fees
+	Currency       string
+	Now            func() time.Time // defaults to time.Now
+
+	mu sync.Mutex // serializes issuing numbers
+}
+
+// PostSummary builds the invoice of a ParkingLog and emits it to the sink. Sessions without a
+// charge and sessions closed as missed exits are not invoiced.
+//
+// A session keeps its invoice number: a session that was invoiced before, e.g. a redelivered exit,
+// is emitted again under the same number. A new number is only taken from the sequence once the
+// sink accepted the invoice, so failed writes leave no gaps.
+func (i *Invoicer) PostSummary(data interface{}) error {
+	parkingLog, ok := data.(models.ParkingLog)
+	if !ok {
+		return fmt.Errorf("cannot invoice %T", data)
+	}
+	if parkingLog.FeeCents <= 0 || parkingLog.Status == models.StatusMissedExit {
+		logger.Log.Debug().Msgf("No invoice for %s", parkingLog.VehiclePlate)
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	numbersKey := NumbersKeyPrefix + parkingLog.VehiclePlate
+	entryField := parkingLog.EntryDateTime.UTC().Format(time.RFC3339Nano)
+	issued, err := i.Numbers.GetAllFields(numbersKey)
+	if err != nil {
+		return fmt.Errorf("error reading invoice numbers of %s: %w", parkingLog.VehiclePlate, err)
+	}
+	if number, ok := issued[entryField]; ok {
+		sequence, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid invoice number %q of %s: %w", number, parkingLog.VehiclePlate, err)
+		}
+		invoice := i.Build(parkingLog, sequence)
+		logger.Log.Info().Msgf("Emitting invoice %s for %s again", invoice.Number, invoice.VehiclePlate)
+		return i.Sink.PostSummary(invoice)
+	}
+
+	last, err := i.Numbers.GetCounter(SequenceKey)
+	if err != nil {
+		return fmt.Errorf("error reading invoice sequence: %w", err)
+	}
+	invoice := i.Build(parkingLog, last+1)
+	if err := i.Sink.PostSummary(invoice); err != nil {
+		return err
+	}
+
+	if _, err := i.Numbers.Increment(SequenceKey); err != nil {
+		return fmt.Errorf("error issuing invoice number %s: %w", invoice.Number, err)
+	}
+	if err := i.Numbers.AddFieldsToHash(numbersKey, map[string]string{entryField: strconv.FormatInt(last+1, 10)}); err != nil {
+		return fmt.Errorf("error recording invoice number %s: %w", invoice.Number, err)
+	}
+	logger.Log.Info().Msgf("Issued invoice %s for %s", invoice.Number, invoice.VehiclePlate)
+	return nil
+}
+
+// Build creates the invoice of a parking session with the given invoice number.
+func (i *Invoicer) Build(parkingLog models.ParkingLog, sequence int64) Invoice {
+	now := time.Now()
+	if i.Now != nil {
+		now = i.Now()
+	}
+
+	grossFee := parkingLog.FeeCents
+	for _, discount := range parkingLog.Discounts {
+		grossFee += discount.AmountCents
+	}
+
+	lines := []LineItem{{
+		Description: fmt.Sprintf("Parking %s - %s (%s)",
+			parkingLog.EntryDateTime.UTC().Format(time.RFC3339),
+			parkingLog.ExitDateTime.UTC().Format(time.RFC3339),
+			parkingLog.Duration),
+		AmountCents: grossFee,
+	}}
+	for _, discount := range parkingLog.Discounts {
+		lines = append(lines, LineItem{Description: discount.Description, AmountCents: -discount.AmountCents})
+	}
+
+	subtotal := parkingLog.FeeCents
+	tax := int64(math.Round(float64(subtotal) * i.TaxRatePercent / 100))
+
+	return Invoice{
+		Number:         fmt.Sprintf("INV-%08d", sequence),
+		IssuedAt:       now.UTC(),
+		VehiclePlate:   parkingLog.VehiclePlate,
+		EntryDateTime:  parkingLog.EntryDateTime,
+		ExitDateTime:   parkingLog.ExitDateTime,
+		Lines:          lines,
+		SubtotalCents:  subtotal,
+		TaxRatePercent: i.TaxRatePercent,
+		TaxCents:       tax,
+		TotalCents:     subtotal + tax,
+		Currency:       i.Currency,
+	}
+}
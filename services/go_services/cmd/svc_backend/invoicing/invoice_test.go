@@ -0,0 +1,235 @@
+package invoicing
+
+import (
+	"bytes"
+	"errors"
+	"go_services/cmd/svc_backend/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNumbers struct {
+	value   int64
+	numbers map[string]map[string]string
+	err     error
+}
+
+func (m *mockNumbers) GetCounter(key string) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	return m.value, nil
+}
+
+func (m *mockNumbers) Increment(key string) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.value++
+	return m.value, nil
+}
+
+func (m *mockNumbers) AddFieldsToHash(hashKey string, fields map[string]string) error {
+	if m.numbers == nil {
+		m.numbers = map[string]map[string]string{}
+	}
+	if m.numbers[hashKey] == nil {
+		m.numbers[hashKey] = map[string]string{}
+	}
+	for field, value := range fields {
+		m.numbers[hashKey][field] = value
+	}
+	return nil
+}
+
+func (m *mockNumbers) GetAllFields(hashKey string) (map[string]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.numbers[hashKey], nil
+}
+
+type mockSink struct {
+	posted []interface{}
+	err    error
+}
+
+func (m *mockSink) PostSummary(data interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.posted = append(m.posted, data)
+	return nil
+}
+
+var (
+	entryTime = time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	exitTime  = time.Date(2024, 9, 11, 13, 30, 0, 0, time.UTC)
+	issuedAt  = time.Date(2024, 9, 11, 13, 31, 0, 0, time.UTC)
+)
+
+func TestInvoicer_PostSummary(t *testing.T) {
+	tests := []struct {
+		name            string
+		parkingLog      models.ParkingLog
+		sequenceErr     error
+		expectedInvoice *Invoice
+		expectedError   bool
+	}{
+		{
+			name: "Fee With Discount And Tax",
+			parkingLog: models.ParkingLog{
+				VehiclePlate:  "ABC-123",
+				EntryDateTime: entryTime,
+				ExitDateTime:  exitTime,
+				Duration:      "3h30m0s",
+				FeeCents:      400,
+				Discounts: []models.AppliedDiscount{
+					{ValidationID: "v1", Description: "120 free minutes validated by Bookshop", AmountCents: 400},
+				},
+			},
+			expectedInvoice: &Invoice{
+				Number:        "INV-00000001",
+				IssuedAt:      issuedAt,
+				VehiclePlate:  "ABC-123",
+				EntryDateTime: entryTime,
+				ExitDateTime:  exitTime,
+				Lines: []LineItem{
+					{Description: "Parking 2024-09-11T10:00:00Z - 2024-09-11T13:30:00Z (3h30m0s)", AmountCents: 800},
+					{Description: "120 free minutes validated by Bookshop", AmountCents: -400},
+				},
+				SubtotalCents:  400,
+				TaxRatePercent: 24,
+				TaxCents:       96,
+				TotalCents:     496,
+				Currency:       "EUR",
+			},
+		},
+		{
+			name:       "Free Session Not Invoiced",
+			parkingLog: models.ParkingLog{VehiclePlate: "ABC-123", FeeCents: 0},
+		},
+		{
+			name:       "Missed Exit Not Invoiced",
+			parkingLog: models.ParkingLog{VehiclePlate: "ABC-123", FeeCents: 200, Status: models.StatusMissedExit},
+		},
+		{
+			name:          "Sequence Error",
+			parkingLog:    models.ParkingLog{VehiclePlate: "ABC-123", FeeCents: 200},
+			sequenceErr:   errors.New("redis down"),
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &mockSink{}
+			invoicer := &Invoicer{
+				Numbers:        &mockNumbers{err: tt.sequenceErr},
+				Sink:           sink,
+				TaxRatePercent: 24,
+				Currency:       "EUR",
+				Now:            func() time.Time { return issuedAt },
+			}
+
+			err := invoicer.PostSummary(tt.parkingLog)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				assert.Empty(t, sink.posted)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.expectedInvoice == nil {
+				assert.Empty(t, sink.posted)
+				return
+			}
+			assert.Equal(t, []interface{}{*tt.expectedInvoice}, sink.posted)
+		})
+	}
+}
+
+func TestInvoicer_SequentialNumbers(t *testing.T) {
+	invoicer := &Invoicer{Numbers: &mockNumbers{value: 41}, Sink: &mockSink{}}
+
+	first := models.ParkingLog{VehiclePlate: "ABC-123", EntryDateTime: entryTime, FeeCents: 100}
+	second := models.ParkingLog{VehiclePlate: "ABC-123", EntryDateTime: exitTime, FeeCents: 100}
+	assert.NoError(t, invoicer.PostSummary(first))
+	assert.NoError(t, invoicer.PostSummary(second))
+
+	posted := invoicer.Sink.(*mockSink).posted
+	assert.Equal(t, "INV-00000042", posted[0].(Invoice).Number)
+	assert.Equal(t, "INV-00000043", posted[1].(Invoice).Number)
+}
+
+func TestInvoicer_StableNumbers(t *testing.T) {
+	numbers := &mockNumbers{}
+	sink := &mockSink{err: errors.New("api down")}
+	invoicer := &Invoicer{Numbers: numbers, Sink: sink}
+	session := models.ParkingLog{VehiclePlate: "ABC-123", EntryDateTime: entryTime, FeeCents: 100}
+
+	// a failed write takes no number
+	assert.Error(t, invoicer.PostSummary(session))
+	assert.Equal(t, int64(0), numbers.value)
+
+	sink.err = nil
+	assert.NoError(t, invoicer.PostSummary(session))
+	// a redelivered session is emitted again under its number
+	assert.NoError(t, invoicer.PostSummary(session))
+	assert.NoError(t, invoicer.PostSummary(models.ParkingLog{VehiclePlate: "XYZ-789", EntryDateTime: entryTime, FeeCents: 100}))
+
+	var issued []string
+	for _, posted := range sink.posted {
+		issued = append(issued, posted.(Invoice).Number)
+	}
+	assert.Equal(t, []string{"INV-00000001", "INV-00000001", "INV-00000002"}, issued)
+	assert.Equal(t, int64(2), numbers.value)
+}
+
+func TestRender(t *testing.T) {
+	invoice := Invoice{
+		Number:       "INV-00000001",
+		IssuedAt:     issuedAt,
+		VehiclePlate: "ABC-123",
+		Lines: []LineItem{
+			{Description: "Parking", AmountCents: 800},
+			{Description: "<b>Bookshop</b>", AmountCents: -400},
+		},
+		SubtotalCents:  400,
+		TaxRatePercent: 24,
+		TaxCents:       96,
+		TotalCents:     496,
+		Currency:       "EUR",
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, RenderText(&text, invoice))
+	assert.Contains(t, text.String(), "INVOICE INV-00000001")
+	assert.Contains(t, text.String(), "-4.00")
+	assert.Contains(t, text.String(), "Tax 24%")
+	assert.Contains(t, text.String(), "4.96")
+
+	var html bytes.Buffer
+	assert.NoError(t, RenderHTML(&html, invoice))
+	assert.Contains(t, html.String(), "<h1>Invoice INV-00000001</h1>")
+	assert.Contains(t, html.String(), "&lt;b&gt;Bookshop&lt;/b&gt;")
+	assert.Contains(t, html.String(), "Total EUR")
+}
+
+func TestFileSink_PostSummary(t *testing.T) {
+	dir := t.TempDir()
+	sink := &FileSink{Dir: filepath.Join(dir, "invoices")}
+
+	err := sink.PostSummary(Invoice{Number: "INV-00000001", TotalCents: 496})
+	assert.NoError(t, err)
+
+	for _, ext := range []string{".json", ".txt", ".html"} {
+		_, err := os.Stat(filepath.Join(dir, "invoices", "INV-00000001"+ext))
+		assert.NoError(t, err, ext)
+	}
+	assert.Error(t, sink.PostSummary("not an invoice"))
+}
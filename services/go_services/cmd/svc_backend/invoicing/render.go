@@ -0,0 +1,62 @@
+package invoicing
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	texttemplate "text/template"
+)
+
+// formatCents renders an amount in cents as a decimal with two places.
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+var templateFuncs = map[string]any{"money": formatCents}
+
+const textLayout = `INVOICE {{.Number}}
+Issued: {{.IssuedAt.Format "2006-01-02 15:04 MST"}}
+Vehicle: {{.VehiclePlate}}
+
+{{range .Lines}}{{printf "%-60s" .Description}} {{printf "%10s" (money .AmountCents)}}
+{{end}}
+{{printf "%-60s" "Subtotal"}} {{printf "%10s" (money .SubtotalCents)}}
+{{printf "%-60s" (printf "Tax %g%%" .TaxRatePercent)}} {{printf "%10s" (money .TaxCents)}}
+{{printf "%-60s" (printf "Total %s" .Currency)}} {{printf "%10s" (money .TotalCents)}}
+`
+
+const htmlLayout = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Invoice {{.Number}}</title></head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>Issued: {{.IssuedAt.Format "2006-01-02 15:04 MST"}}<br>Vehicle: {{.VehiclePlate}}</p>
+<table>
+{{range .Lines}}<tr><td>{{.Description}}</td><td align="right">{{money .AmountCents}}</td></tr>
+{{end}}<tr><td>Subtotal</td><td align="right">{{money .SubtotalCents}}</td></tr>
+<tr><td>Tax {{.TaxRatePercent}}%</td><td align="right">{{money .TaxCents}}</td></tr>
+<tr><th align="left">Total {{.Currency}}</th><th align="right">{{money .TotalCents}}</th></tr>
+</table>
+</body>
+</html>
+`
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.New("invoice").Funcs(templateFuncs).Parse(textLayout))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("invoice").Funcs(templateFuncs).Parse(htmlLayout))
+)
+
+// RenderText writes the invoice as plain text.
+func RenderText(w io.Writer, invoice Invoice) error {
+	return textTemplate.Execute(w, invoice)
+}
+
+// RenderHTML writes the invoice as an HTML document.
+func RenderHTML(w io.Writer, invoice Invoice) error {
+	return htmlTemplate.Execute(w, invoice)
+}
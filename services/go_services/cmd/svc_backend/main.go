@@ -1,52 +1,78 @@
 package main
 
 import (
+	"fmt"
 	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/invoicing"
+	"go_services/cmd/svc_backend/matching"
+	"go_services/cmd/svc_backend/plates"
 	"go_services/cmd/svc_backend/processors"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/reports"
+	pkgconfig "go_services/pkg/config"
 	"go_services/pkg/logger"
 	"go_services/pkg/rabbitmq"
-	"go_services/pkg/redis"
 	"go_services/pkg/restapi"
+	"go_services/pkg/transport"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// print config for debug purposes
+// print config for debug purposes, with secrets redacted
 func printConfig(cfg *config.Config) {
-	logger.Log.Info().
-		Str("RabbitMQURL", cfg.RabbitMQURL).
-		Str("RedisAddress", cfg.RedisAddress).
-		Str("RedisPassword", cfg.RedisPassword).
-		Int("RedisDB", cfg.RedisDB).
-		Str("EntryQueueName", cfg.EntryQueueName).
-		Str("ExitQueueName", cfg.ExitQueueName).
-		Str("APIURL", cfg.APIURL).
-		Msg("Configuration settings")
+	entries, err := pkgconfig.Entries(cfg)
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("Failed to print configuration")
+		return
+	}
+	event := logger.Log.Info()
+	for _, entry := range entries {
+		event = event.Interface(entry.Key, entry.Value)
+	}
+	event.Msg("Configuration settings")
 }
 
-// loadConfig loads the application configuration
-func loadConfig() *config.Config {
-	cfg := config.LoadConfig()
+// loadConfig loads the application configuration and returns it with how it was loaded. Invalid
+// configurations are reported on stderr before exiting, and --print-config prints the effective
+// configuration and exits.
+func loadConfig() (*config.Config, *pkgconfig.Loaded) {
+	cfg, loaded, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "svc_backend: invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if loaded.PrintConfig {
+		if err := pkgconfig.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "svc_backend: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	logger.InitLogger(cfg.LogLevel)
+	if loaded.File != "" {
+		logger.Log.Info().Msgf("Loaded configuration file %s", loaded.File)
+	}
 	printConfig(cfg)
-	return cfg
+	return cfg, loaded
 }
 
-// initializeServices initializes the RabbitMQ and Redis clients
-func initializeServices(cfg *config.Config) (*rabbitmq.RabbitMQClient, *redis.RedisClient, error) {
-	rabbitMQClient, err := rabbitmq.GetRabbitMQClient(cfg.RabbitMQURL)
+// initializeServices connects to the message broker and the storage backend
+func initializeServices(cfg *config.Config) (*messaging, storage, func(), error) {
+	broker, err := openMessaging(cfg)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	redisClient, err := redis.GetRedisClient(cfg.RedisAddress, cfg.RedisPassword, cfg.RedisDB)
+	store, closeStore, err := openStorage(cfg)
 	if err != nil {
-		rabbitMQClient.Close()
-		return nil, nil, err
+		broker.close()
+		return nil, nil, nil, err
 	}
 
-	return rabbitMQClient, redisClient, nil
+	return broker, store, closeStore, nil
 }
 
 // startMetricsServer starts the Prometheus metrics HTTP server
@@ -60,60 +86,287 @@ func startMetricsServer() {
 	}()
 }
 
-// setupEventProcessors sets up the entry and exit event processors
-func setupEventProcessors(cfg *config.Config, rabbitMQClient *rabbitmq.RabbitMQClient, redisClient *redis.RedisClient) error {
+// loadPlateNormalizer builds the plate normalizer from the country rules file, if one is configured,
+// applying the rules of the configured countries only
+func loadPlateNormalizer(rulesFile, countries string) (*plates.Normalizer, error) {
+	if rulesFile == "" {
+		return plates.NewNormalizer(nil)
+	}
+	rules, err := plates.LoadRules(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	if countries != "" {
+		if rules, err = plates.ForCountries(rules, strings.Split(countries, ",")); err != nil {
+			return nil, err
+		}
+	}
+	logger.Log.Debug().Msgf("Loaded %d plate format rules", len(rules))
+	return plates.NewNormalizer(rules)
+}
+
+// loadPlateRegistry builds the permit/whitelist/blacklist registry from the configured file and Redis hash
+func loadPlateRegistry(cfg *config.Config, store storage, normalizer *plates.Normalizer) (*registry.Registry, error) {
+	var entries []registry.Entry
+	if cfg.PlateRegistryFile != "" {
+		fileEntries, err := registry.LoadFile(cfg.PlateRegistryFile)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	if cfg.PlateRegistryRedisKey != "" {
+		hashEntries, err := registry.LoadHash(store, cfg.PlateRegistryRedisKey)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, hashEntries...)
+	}
+
+	plateRegistry, err := registry.NewRegistry(entries, normalizer.Normalize)
+	if err != nil {
+		return nil, err
+	}
+	logger.Log.Debug().Msgf("Loaded %d plate registry entries", plateRegistry.Len())
+	return plateRegistry, nil
+}
+
+// newInvoicer creates the invoicer when an invoice file or API sink is configured
+func newInvoicer(cfg *config.Config, store storage) *invoicing.Invoicer {
+	var sinks processors.MultiPoster
+	if cfg.InvoiceOutputDir != "" {
+		sinks = append(sinks, &invoicing.FileSink{Dir: cfg.InvoiceOutputDir})
+	}
+	if cfg.InvoiceAPIURL != "" {
+		sinks = append(sinks, &restapi.HTTPClientPoster{Client: &http.Client{}, APIURL: cfg.InvoiceAPIURL})
+	}
+	if len(sinks) == 0 {
+		logger.Log.Debug().Msg("Invoicing disabled")
+		return nil
+	}
+
+	return &invoicing.Invoicer{
+		Numbers:        store,
+		Sink:           sinks,
+		TaxRatePercent: cfg.InvoiceTaxRatePercent,
+		Currency:       cfg.InvoiceCurrency,
+	}
+}
+
+// pipeline holds the processors built from one configuration.
+type pipeline struct {
+	entry      *processors.EntryEventProcessor
+	exit       *processors.ExitEventProcessor
+	validation *processors.ValidationEventProcessor
+	overstay   *processors.OverstayScanner // nil when overstay detection is disabled
+
+	// processors of replayed stream events, which post no summaries unless enabled
+	replayEntry *processors.EntryEventProcessor
+	replayExit  *processors.ExitEventProcessor
+}
+
+// buildPipeline creates the event processors and the overstay scanner for the configuration
+func buildPipeline(cfg *config.Config, publisher transport.Publisher, store storage, sessionStore processors.SessionStore) (*pipeline, error) {
+	duplicatePolicy, err := processors.ParseDuplicatePolicy(cfg.DuplicateEntryPolicy)
+	if err != nil {
+		return nil, err
+	}
+	normalizer, err := loadPlateNormalizer(cfg.PlateRulesFile, cfg.PlateCountries)
+	if err != nil {
+		return nil, err
+	}
+	plateRegistry, err := loadPlateRegistry(cfg, store, normalizer)
+	if err != nil {
+		return nil, err
+	}
+	archive := &reports.Archive{Store: store, MaxItems: cfg.ArchiveMaxItems}
+	alertPublisher := &queueAlertPublisher{publisher: publisher, queueName: cfg.AlertQueueName}
+	reviewPublisher := &queueReviewPublisher{publisher: publisher, queueName: cfg.ReviewQueueName}
+
 	// Initialize EntryEventProcessor
 	entryEvtProcessor := &processors.EntryEventProcessor{
-		DataStore: redisClient,
+		Sessions:        sessionStore,
+		DuplicatePolicy: duplicatePolicy,
+		AlertPublisher:  alertPublisher,
+		SummaryPoster:   archive,
+		Normalizer:      normalizer,
+		Registry:        plateRegistry,
+		MinConfidence:   cfg.MinReadConfidence,
+		ReviewPublisher: reviewPublisher,
 	}
 
-	// Handle Entry Events
-	if err := rabbitMQClient.ConsumeQueue(cfg.EntryQueueName, entryEvtProcessor); err != nil {
-		return err
+	// Configure and create SummaryPoster implementations; summaries are archived for reports
+	// and posted to the API
+	summaryPoster := processors.MultiPoster{
+		archive,
+		&restapi.HTTPClientPoster{
+			Client: &http.Client{},
+			APIURL: cfg.APIURL,
+		},
 	}
-	logger.Log.Debug().Msg("Entry queue consumer set up")
-
-	// Configure and create SummaryPoster implementation
-	summaryPoster := &restapi.HTTPClientPoster{
-		Client: &http.Client{},
-		APIURL: cfg.APIURL,
+	if invoicer := newInvoicer(cfg, store); invoicer != nil {
+		summaryPoster = append(summaryPoster, invoicer)
+	}
+	// completed sessions are also recorded for finance when sessions are kept in SQL
+	if recorder, ok := sessionStore.(processors.SummaryPoster); ok {
+		summaryPoster = append(summaryPoster, recorder)
 	}
 
 	// Initialize ExitEventProcessor
 	exitEvtProcessor := &processors.ExitEventProcessor{
-		DataStore:     redisClient,
-		SummaryPoster: summaryPoster,
+		Sessions:        sessionStore,
+		SummaryPoster:   summaryPoster,
+		Tariff:          processors.Tariff{HourlyRateCents: cfg.TariffHourlyRateCents},
+		OrphanRecorder:  archive,
+		Normalizer:      normalizer,
+		Registry:        plateRegistry,
+		MinConfidence:   cfg.MinReadConfidence,
+		ReviewPublisher: reviewPublisher,
+		Validations:     store,
+	}
+	if cfg.PlateMatchingEnabled {
+		exitEvtProcessor.PlateMatcher = &matching.Matcher{
+			AcceptThreshold: cfg.PlateMatchAcceptThreshold,
+			ReviewThreshold: cfg.PlateMatchReviewThreshold,
+		}
 	}
 
-	// Handle Exit Events
-	if err := rabbitMQClient.ConsumeQueue(cfg.ExitQueueName, exitEvtProcessor); err != nil {
-		return err
+	// Initialize ValidationEventProcessor
+	validationEvtProcessor := &processors.ValidationEventProcessor{
+		Sessions:    sessionStore,
+		Validations: store,
+		Normalizer:  normalizer,
+	}
+
+	// Initialize the OverstayScanner when a threshold is configured
+	var scanner *processors.OverstayScanner
+	if cfg.OverstayThreshold > 0 {
+		scanner = &processors.OverstayScanner{
+			Sessions:       sessionStore,
+			AlertPublisher: alertPublisher,
+			SummaryPoster:  archive,
+			Alerted:        store,
+			Threshold:      cfg.OverstayThreshold,
+			CloseOut:       cfg.OverstayCloseOut,
+		}
+	}
+
+	// Replayed events rebuild the sessions without raising their alerts, reviews and orphan exits again,
+	// nor posting their summaries unless configured
+	replayEntry, replayExit := *entryEvtProcessor, *exitEvtProcessor
+	replayEntry.AlertPublisher, replayEntry.ReviewPublisher = discardSink{}, discardSink{}
+	replayExit.ReviewPublisher, replayExit.OrphanRecorder = discardSink{}, discardSink{}
+	if !cfg.StreamReplayPostSummaries {
+		replayEntry.SummaryPoster = processors.MultiPoster{}
+		replayExit.SummaryPoster = processors.MultiPoster{}
+	}
+
+	return &pipeline{
+		entry:       entryEvtProcessor,
+		exit:        exitEvtProcessor,
+		validation:  validationEvtProcessor,
+		overstay:    scanner,
+		replayEntry: &replayEntry,
+		replayExit:  &replayExit,
+	}, nil
+}
+
+// setupEventProcessors sets up the queue consumers; they hand the messages to the service's current processors
+func setupEventProcessors(cfg *config.Config, broker *messaging, svc *service, offsets rabbitmq.OffsetStore) error {
+	switch cfg.EventQueueMode {
+	case streamEventQueue:
+		// Handle Entry and Exit Events of the stream in order, with the replay processors up to the stored offset
+		if err := consumeEventStream(cfg, broker.rabbitMQ, svc, offsets); err != nil {
+			return err
+		}
+		logger.Log.Debug().Msg("Event stream consumer set up")
+	case singleEventQueue:
+		// Handle Entry and Exit Events in order, dispatched by their message type
+		handlers := map[string]transport.Handler{transport.EntryEvent: &svc.entry, transport.ExitEvent: &svc.exit}
+		if err := broker.consumer.ConsumeTyped(cfg.EventQueueName, handlers); err != nil {
+			return err
+		}
+		logger.Log.Debug().Msg("Event queue consumer set up")
+	default:
+		// Handle Entry Events
+		if err := broker.consumer.Consume(cfg.EntryQueueName, &svc.entry); err != nil {
+			return err
+		}
+		logger.Log.Debug().Msg("Entry queue consumer set up")
+
+		// Handle Exit Events
+		if err := broker.consumer.Consume(cfg.ExitQueueName, &svc.exit); err != nil {
+			return err
+		}
+		logger.Log.Debug().Msg("Exit queue consumer set up")
+	}
+
+	// Handle Validation Events when a validation queue is configured
+	if cfg.ValidationQueueName != "" {
+		if err := broker.consumer.Consume(cfg.ValidationQueueName, &svc.validation); err != nil {
+			return err
+		}
+		logger.Log.Debug().Msg("Validation queue consumer set up")
 	}
-	logger.Log.Debug().Msg("Exit queue consumer set up")
 
 	return nil
 }
 
 func main() {
 	// Load configuration
-	cfg := loadConfig()
+	cfg, loaded := loadConfig()
+
+	// Run the report subcommand instead of the consumers when requested
+	if len(loaded.Args) > 0 && loaded.Args[0] == config.ReportCommand {
+		if err := runReport(cfg, loaded.Args[1:]); err != nil {
+			logger.Log.Fatal().Err(err).Msg("Failed to generate reports")
+		}
+		return
+	}
+	if len(loaded.Args) > 0 && loaded.Args[0] == config.MigrateSessionsCommand {
+		if err := runMigrateSessions(cfg); err != nil {
+			logger.Log.Fatal().Err(err).Msg("Failed to migrate sessions")
+		}
+		return
+	}
 
 	// Initialize services
-	rabbitMQClient, redisClient, err := initializeServices(cfg)
+	broker, store, closeStore, err := initializeServices(cfg)
 	if err != nil {
 		logger.Log.Fatal().Err(err).Msg("Failed to initialize services")
 	}
-	defer rabbitMQClient.Close()
-	defer redisClient.Client.Close()
+	defer broker.close()
+	defer closeStore()
+
+	// Parking sessions are kept in hashes keyed by plate or in a SQL database
+	sessionStore, closeSessions, err := openSessionStore(cfg, store)
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to open session store")
+	}
+	defer closeSessions()
 
 	// Start the Prometheus metrics server
 	startMetricsServer()
 
+	// Build the event processors and start detecting overstayed sessions
+	svc := &service{
+		build: func(cfg *config.Config) (*pipeline, error) {
+			return buildPipeline(cfg, broker.publisher, store, sessionStore)
+		},
+		setProcessRetry: broker.setProcessRetry,
+	}
+	if err := svc.apply(cfg); err != nil {
+		logger.Log.Fatal().Err(err).Msg("Failed to set up event processors")
+	}
+
 	// Set up event processors
-	if err := setupEventProcessors(cfg, rabbitMQClient, redisClient); err != nil {
+	if err := setupEventProcessors(cfg, broker, svc, &fileOffsetStore{path: cfg.StreamOffsetFile}); err != nil {
 		logger.Log.Fatal().Err(err).Msg("Failed to set up event processors")
 	}
 
+	// Reload the configuration on SIGHUP and when the config file changes
+	go svc.watchReloads(os.Args[1:], loaded.File)
+
 	// Keep the main function running
 	select {}
 }
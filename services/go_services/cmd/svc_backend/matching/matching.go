@@ -0,0 +1,126 @@
+package matching
+
+import (
+	"strings"
+)
+
+// ocrConfusionCost is the cost of substituting characters that ANPR cameras commonly confuse,
+// compared to 1 for any other edit.
+const ocrConfusionCost = 0.2
+
+// ambiguityMargin is how close the second best candidate must be to the best one for a match to be
+// ambiguous.
+const ambiguityMargin = 0.05
+
+// ocrConfusions lists character pairs that are easily misread for one another.
+var ocrConfusions = map[rune]string{
+	'O': "0DQ",
+	'0': "ODQ",
+	'D': "0O",
+	'Q': "0O",
+	'I': "1L",
+	'1': "IL",
+	'L': "1I",
+	'B': "8",
+	'8': "B",
+	'S': "5",
+	'5': "S",
+	'Z': "2",
+	'2': "Z",
+	'G': "6",
+	'6': "G",
+}
+
+// Match is a candidate plate paired with the confidence that it is the same vehicle. A match is
+// ambiguous when another candidate, RunnerUp, is about as similar.
+type Match struct {
+	Plate      string
+	Confidence float64
+	Ambiguous  bool
+	RunnerUp   string
+}
+
+// Matcher pairs misread plates with known plates. Matches at or above AcceptThreshold can be paired
+// automatically; matches at or above ReviewThreshold need a manual review.
+type Matcher struct {
+	AcceptThreshold float64
+	ReviewThreshold float64
+}
+
+// BestMatch returns the candidate most similar to plate, or false when none reaches the review threshold.
+// When the second best candidate is within a small margin of the best, the match is ambiguous.
+func (m *Matcher) BestMatch(plate string, candidates []string) (Match, bool) {
+	var best, second Match
+	found := 0
+	for _, candidate := range candidates {
+		confidence := Confidence(plate, candidate)
+		if confidence < m.ReviewThreshold {
+			continue
+		}
+		current := Match{Plate: candidate, Confidence: confidence}
+		switch {
+		case found == 0 || confidence > best.Confidence:
+			best, second = current, best
+		case found == 1 || confidence > second.Confidence:
+			second = current
+		}
+		found++
+	}
+	if found > 1 && best.Confidence-second.Confidence <= ambiguityMargin {
+		best.Ambiguous = true
+		best.RunnerUp = second.Plate
+	}
+	return best, found > 0
+}
+
+// Accepts reports whether the match is confident and unambiguous enough to be paired without review.
+func (m *Matcher) Accepts(match Match) bool {
+	return !match.Ambiguous && match.Confidence >= m.AcceptThreshold
+}
+
+// Confidence returns a score between 0 and 1 of how likely two plate reads are the same plate.
+func Confidence(a, b string) float64 {
+	a, b = strings.ToUpper(a), strings.ToUpper(b)
+	longest := len([]rune(a))
+	if n := len([]rune(b)); n > longest {
+		longest = n
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - Distance(a, b)/float64(longest)
+}
+
+// Distance is the Levenshtein distance between two plates where substitutions of
+// commonly confused characters are cheaper than other edits.
+func Distance(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]float64, len(rb)+1)
+	current := make([]float64, len(rb)+1)
+	for j := range previous {
+		previous[j] = float64(j)
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = float64(i)
+		for j := 1; j <= len(rb); j++ {
+			current[j] = min(
+				previous[j]+1,  // deletion
+				current[j-1]+1, // insertion
+				previous[j-1]+substitutionCost(ra[i-1], rb[j-1]),
+			)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func substitutionCost(a, b rune) float64 {
+	if a == b {
+		return 0
+	}
+	if strings.ContainsRune(ocrConfusions[a], b) {
+		return ocrConfusionCost
+	}
+	return 1
+}
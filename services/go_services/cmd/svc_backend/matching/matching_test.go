@@ -0,0 +1,104 @@
+package matching
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"ABC123", "ABC123", 0},
+		{"ABC123", "A8C123", 0.2},
+		{"OIB", "018", 0.6},
+		{"ABC123", "ABC124", 1},
+		{"ABC123", "ABC12", 1},
+		{"", "ABC", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_"+tt.b, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, Distance(tt.a, tt.b), 1e-9)
+		})
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	assert.Equal(t, 1.0, Confidence("abc123", "ABC123"))
+	assert.InDelta(t, 1-0.2/6, Confidence("ABC123", "A8C123"), 1e-9)
+	assert.InDelta(t, 1-1.0/6, Confidence("ABC123", "ABC124"), 1e-9)
+	assert.Equal(t, 0.0, Confidence("", ""))
+}
+
+func TestMatcher_BestMatch(t *testing.T) {
+	matcher := &Matcher{AcceptThreshold: 0.9, ReviewThreshold: 0.75}
+
+	tests := []struct {
+		name           string
+		plate          string
+		candidates     []string
+		expectedFound  bool
+		expectedPlate  string
+		expectedAccept bool
+	}{
+		{
+			name:           "OCR Confusion Accepted",
+			plate:          "A8C123",
+			candidates:     []string{"XYZ789", "ABC123", "ABD123"},
+			expectedFound:  true,
+			expectedPlate:  "ABC123",
+			expectedAccept: true,
+		},
+		{
+			name:           "Single Edit Needs Review",
+			plate:          "ABC124",
+			candidates:     []string{"ABC123", "XYZ789"},
+			expectedFound:  true,
+			expectedPlate:  "ABC123",
+			expectedAccept: false,
+		},
+		{
+			name:           "Tie Needs Review",
+			plate:          "LMN45D",
+			candidates:     []string{"LMN450", "LMN45O", "XYZ789"},
+			expectedFound:  true,
+			expectedPlate:  "LMN450",
+			expectedAccept: false,
+		},
+		{
+			name:           "Within Margin Needs Review",
+			plate:          "A8C123",
+			candidates:     []string{"ABC1Z3", "ABC123"},
+			expectedFound:  true,
+			expectedPlate:  "ABC123",
+			expectedAccept: false,
+		},
+		{
+			name:          "No Near Match",
+			plate:         "QWE456",
+			candidates:    []string{"ABC123", "XYZ789"},
+			expectedFound: false,
+		},
+		{
+			name:          "No Candidates",
+			plate:         "ABC123",
+			candidates:    nil,
+			expectedFound: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, found := matcher.BestMatch(tt.plate, tt.candidates)
+
+			assert.Equal(t, tt.expectedFound, found)
+			if tt.expectedFound {
+				assert.Equal(t, tt.expectedPlate, match.Plate)
+				assert.Equal(t, tt.expectedAccept, matcher.Accepts(match))
+			}
+		})
+	}
+}
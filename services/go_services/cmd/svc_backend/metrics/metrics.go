@@ -31,6 +31,53 @@ var (
 		},
 		[]string{"event_type"},
 	)
+
+	OverstaySessions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "overstay_sessions",
+			Help: "Number of open sessions exceeding the overstay threshold at the last scan.",
+		},
+	)
+
+	OverstayAlerts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "overstay_alerts_total",
+			Help: "Total number of overstay alerts raised.",
+		},
+		[]string{"closed_out"},
+	)
+
+	DuplicateEntries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duplicate_entries_total",
+			Help: "Total number of entries for plates that were already parked.",
+		},
+		[]string{"policy"},
+	)
+
+	PlateMatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "plate_matches_total",
+			Help: "Total number of near match attempts for exits without an exact entry, by outcome.",
+		},
+		[]string{"outcome"},
+	)
+
+	LowConfidenceReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "low_confidence_reads_total",
+			Help: "Total number of plate reads below the minimum camera confidence sent for review.",
+		},
+		[]string{"event_type"},
+	)
+
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of configuration reloads, by result.",
+		},
+		[]string{"result"},
+	)
 )
 
 func init() {
@@ -38,4 +85,10 @@ func init() {
 	prometheus.MustRegister(EventProcessingLatency)
 	prometheus.MustRegister(EventProcessingFails)
 	prometheus.MustRegister(EventProcessingSuccesses)
+	prometheus.MustRegister(OverstaySessions)
+	prometheus.MustRegister(OverstayAlerts)
+	prometheus.MustRegister(DuplicateEntries)
+	prometheus.MustRegister(PlateMatches)
+	prometheus.MustRegister(LowConfidenceReads)
+	prometheus.MustRegister(ConfigReloads)
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/logger"
+)
+
+// runMigrateSessions implements the "migrate-sessions" subcommand: it moves the sessions stored by
+// earlier versions under the bare plate to the hash-tagged key of the normalized plate. It is run once,
+// with the consumers stopped, when upgrading a store written by those versions.
+func runMigrateSessions(cfg *config.Config) error {
+	normalizer, err := loadPlateNormalizer(cfg.PlateRulesFile, cfg.PlateCountries)
+	if err != nil {
+		return err
+	}
+	store, closeStore, err := openStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	moved, err := sessions.MigrateBareKeys(store, normalizer.Normalize)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info().Msgf("Moved %d sessions to hash-tagged keys", moved)
+	return nil
+}
@@ -7,6 +7,7 @@ type EntryEvent struct {
 	ID            string    `json:"id"`
 	VehiclePlate  string    `json:"vehicle_plate"`
 	EntryDateTime time.Time `json:"entry_date_time"`
+	CameraRead
 }
 
 // ExitEvent represents an event payload when a vehicle exits the parking area.
@@ -14,12 +15,120 @@ type ExitEvent struct {
 	ID           string    `json:"id"`
 	VehiclePlate string    `json:"vehicle_plate"`
 	ExitDateTime time.Time `json:"exit_date_time"`
+	CameraRead
+}
+
+// CameraRead holds the optional details an ANPR camera reports with a plate read.
+// A zero Confidence means the camera did not report one.
+type CameraRead struct {
+	Confidence  float64 `json:"confidence,omitempty"`
+	CameraID    string  `json:"camera_id,omitempty"`
+	SnapshotURI string  `json:"snapshot_uri,omitempty"`
+}
+
+// Session represents the stay of a vehicle from its entry; ExitDateTime is zero while the vehicle is parked.
+type Session struct {
+	VehiclePlate  string      `json:"vehicle_plate"`
+	RawEntryPlate string      `json:"raw_entry_plate,omitempty"` // plate as read by the entry camera
+	EntryDateTime time.Time   `json:"entry_date_time"`
+	ExitDateTime  time.Time   `json:"exit_date_time"`
+	EntryCamera   *CameraRead `json:"entry_camera,omitempty"`
 }
 
 // ParkingLog represents the log of parking duration to be used as postbody in api calls.
 type ParkingLog struct {
+	VehiclePlate    string    `json:"vehicle_plate"`
+	RawVehiclePlate string    `json:"raw_vehicle_plate,omitempty"` // plate as read by the exit camera
+	RawEntryPlate   string    `json:"raw_entry_plate,omitempty"`   // plate as read by the entry camera
+	ExitDateTime    time.Time `json:"exit_date_time"`
+	EntryDateTime   time.Time `json:"entry_date_time"`
+	Duration        string    `json:"duration"`
+	FeeCents        int64     `json:"fee_cents"`
+	Status          string    `json:"status,omitempty"`
+	Classification  string    `json:"classification,omitempty"`
+
+	// set when the exit plate did not match an entry exactly and was paired with a near match
+	MatchedExitPlate string  `json:"matched_exit_plate,omitempty"`
+	MatchConfidence  float64 `json:"match_confidence,omitempty"`
+
+	EntryCamera *CameraRead `json:"entry_camera,omitempty"`
+	ExitCamera  *CameraRead `json:"exit_camera,omitempty"`
+
+	Discounts []AppliedDiscount `json:"discounts,omitempty"`
+}
+
+// ValidationEvent represents a parking validation, e.g. issued by a shop, attached to the open session of a vehicle.
+type ValidationEvent struct {
+	ID           string    `json:"id"`
+	VehiclePlate string    `json:"vehicle_plate"`
+	Kind         string    `json:"kind"`
+	Value        float64   `json:"value"`
+	Issuer       string    `json:"issuer,omitempty"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+const (
+	// ValidationFreeMinutes makes the given number of minutes free of charge.
+	ValidationFreeMinutes = "free_minutes"
+	// ValidationPercentOff takes the given percentage off the fee.
+	ValidationPercentOff = "percent_off"
+)
+
+// AppliedDiscount itemises a validation applied to the fee of a ParkingLog.
+type AppliedDiscount struct {
+	ValidationID string `json:"validation_id"`
+	Description  string `json:"description"`
+	AmountCents  int64  `json:"amount_cents"`
+}
+
+// StatusMissedExit marks a ParkingLog for a session that was closed without an exit event.
+const StatusMissedExit = "missed_exit"
+
+// Alert represents an anomaly detected while tracking parking sessions.
+type Alert struct {
+	Type          string    `json:"type"`
 	VehiclePlate  string    `json:"vehicle_plate"`
-	ExitDateTime  time.Time `json:"exit_date_time"`
 	EntryDateTime time.Time `json:"entry_date_time"`
-	Duration      string    `json:"duration"`
+	DetectedAt    time.Time `json:"detected_at"`
+	Detail        string    `json:"detail"`
 }
+
+const (
+	// AlertTypeOverstay is raised for sessions open longer than the overstay threshold.
+	AlertTypeOverstay = "overstay"
+	// AlertTypeDuplicateEntry is raised for an entry of a plate that is already parked.
+	AlertTypeDuplicateEntry = "duplicate_entry"
+	// AlertTypeBlacklisted is raised for an entry of a blacklisted plate.
+	AlertTypeBlacklisted = "blacklisted"
+)
+
+// ReviewItem represents an entry or exit event that needs a manual decision before it can be billed.
+type ReviewItem struct {
+	Reason         string      `json:"reason"`
+	EntryEvent     *EntryEvent `json:"entry_event,omitempty"`
+	ExitEvent      *ExitEvent  `json:"exit_event,omitempty"`
+	CandidatePlate string      `json:"candidate_plate,omitempty"`
+	CandidateEntry *time.Time  `json:"candidate_entry_date_time,omitempty"`
+	Confidence     float64     `json:"confidence,omitempty"`
+}
+
+// VehiclePlate returns the plate of the entry or exit event under review.
+func (r ReviewItem) VehiclePlate() string {
+	switch {
+	case r.ExitEvent != nil:
+		return r.ExitEvent.VehiclePlate
+	case r.EntryEvent != nil:
+		return r.EntryEvent.VehiclePlate
+	default:
+		return ""
+	}
+}
+
+const (
+	// ReviewReasonLowConfidenceMatch marks exits whose closest open session is not a confident match.
+	ReviewReasonLowConfidenceMatch = "low_confidence_match"
+	// ReviewReasonAmbiguousMatch marks exits that are about as close to several open sessions.
+	ReviewReasonAmbiguousMatch = "ambiguous_match"
+	// ReviewReasonLowReadConfidence marks events whose plate read is below the minimum camera confidence.
+	ReviewReasonLowReadConfidence = "low_read_confidence"
+)
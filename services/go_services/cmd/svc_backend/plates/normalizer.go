@@ -0,0 +1,115 @@
+package plates
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rule is a country specific plate format. Pattern is matched against the compacted plate
+// (upper case, without whitespace and separators) and Format rewrites it using the pattern's
+// capture groups, e.g. "$1-$2".
+type Rule struct {
+	Country string `json:"country"`
+	Pattern string `json:"pattern"`
+	Format  string `json:"format"`
+}
+
+type compiledRule struct {
+	Rule
+	pattern *regexp.Regexp
+}
+
+// Normalizer turns free-form plate reads into the canonical form used as storage key.
+// A nil Normalizer only compacts plates.
+type Normalizer struct {
+	rules []compiledRule
+}
+
+// NewNormalizer compiles the rules; they are tried in order and the first match wins.
+func NewNormalizer(rules []Rule) (*Normalizer, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plate pattern for %s: %v", rule.Country, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, pattern: pattern})
+	}
+	return &Normalizer{rules: compiled}, nil
+}
+
+// LoadRules reads a JSON array of rules from a file.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plate rules: %v", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("error decoding plate rules: %v", err)
+	}
+	return rules, nil
+}
+
+// ForCountries returns the rules of the countries in the given order, so that a plate matching the
+// formats of several countries, e.g. ABC123 in Finland and Sweden, takes the format of the first.
+// Without countries all rules are returned in their order.
+func ForCountries(rules []Rule, countries []string) ([]Rule, error) {
+	if len(countries) == 0 {
+		return rules, nil
+	}
+	var scoped []Rule
+	for _, country := range countries {
+		country = strings.TrimSpace(country)
+		found := false
+		for _, rule := range rules {
+			if strings.EqualFold(rule.Country, country) {
+				scoped = append(scoped, rule)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no plate rules for country %q", country)
+		}
+	}
+	return scoped, nil
+}
+
+// Normalize upper-cases the plate, strips whitespace and separators and applies the first matching
+// country format. Plates matching no rule are returned compacted.
+func (n *Normalizer) Normalize(plate string) string {
+	compact := Compact(plate)
+	if n == nil {
+		return compact
+	}
+
+	for _, rule := range n.rules {
+		if match := rule.pattern.FindStringSubmatchIndex(compact); match != nil {
+			return string(rule.pattern.ExpandString(nil, rule.Format, compact, match))
+		}
+	}
+	return compact
+}
+
+// Compact upper-cases a plate and removes whitespace and separator characters.
+func Compact(plate string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || isSeparator(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, plate)
+}
+
+func isSeparator(r rune) bool {
+	switch r {
+	case '-', '_', '.', '·', '/', ':':
+		return true
+	}
+	return false
+}
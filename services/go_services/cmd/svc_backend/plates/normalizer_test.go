@@ -0,0 +1,106 @@
+package plates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	normalizer, err := NewNormalizer([]Rule{
+		{Country: "FI", Pattern: `^([A-Z]{2,3})([0-9]{1,3})$`, Format: "$1-$2"},
+		{Country: "GB", Pattern: `^([A-Z]{2}[0-9]{2})([A-Z]{3})$`, Format: "$1 $2"},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		raw      string
+		expected string
+	}{
+		{"ABC 123", "ABC-123"},
+		{"abc-123", "ABC-123"},
+		{"ABC123", "ABC-123"},
+		{" ab.c_12\t3 ", "ABC-123"},
+		{"ab12 cde", "AB12 CDE"},
+		{"plate-42", "PLATE42"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizer.Normalize(tt.raw))
+		})
+	}
+}
+
+func TestForCountries(t *testing.T) {
+	rules := []Rule{
+		{Country: "FI", Pattern: `^([A-Z]{2,3})([0-9]{1,3})$`, Format: "$1-$2"},
+		{Country: "SE", Pattern: `^([A-Z]{3})([0-9]{2}[0-9A-Z])$`, Format: "$1 $2"},
+		{Country: "GB", Pattern: `^([A-Z]{2}[0-9]{2})([A-Z]{3})$`, Format: "$1 $2"},
+	}
+
+	tests := []struct {
+		name          string
+		countries     []string
+		expected      map[string]string
+		expectedError bool
+	}{
+		{
+			name:      "All Rules In File Order",
+			countries: nil,
+			expected:  map[string]string{"abc123": "ABC-123", "abc12d": "ABC 12D", "ab12cde": "AB12 CDE"},
+		},
+		{
+			name:      "Sweden First",
+			countries: []string{"se", " FI"},
+			expected:  map[string]string{"abc123": "ABC 123", "ab123": "AB-123", "ab12cde": "AB12CDE"},
+		},
+		{
+			name:          "Unknown Country",
+			countries:     []string{"SE", "NO"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scoped, err := ForCountries(rules, tt.countries)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			normalizer, err := NewNormalizer(scoped)
+			assert.NoError(t, err)
+			for raw, expected := range tt.expected {
+				assert.Equal(t, expected, normalizer.Normalize(raw), raw)
+			}
+		})
+	}
+}
+
+func TestNormalizer_Nil(t *testing.T) {
+	var normalizer *Normalizer
+
+	assert.Equal(t, "ABC123", normalizer.Normalize("abc 123"))
+}
+
+func TestNewNormalizer_InvalidPattern(t *testing.T) {
+	_, err := NewNormalizer([]Rule{{Country: "XX", Pattern: "([A-Z"}})
+
+	assert.Error(t, err)
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`[{"country": "FI", "pattern": "^([A-Z]{2,3})([0-9]{1,3})$", "format": "$1-$2"}]`), 0o644)
+	assert.NoError(t, err)
+
+	rules, err := LoadRules(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Rule{{Country: "FI", Pattern: `^([A-Z]{2,3})([0-9]{1,3})$`, Format: "$1-$2"}}, rules)
+}
@@ -0,0 +1,17 @@
+package processors
+
+import "go_services/cmd/svc_backend/models"
+
+// cameraRead returns a pointer to the read, or nil when the camera reported no details.
+func cameraRead(read models.CameraRead) *models.CameraRead {
+	if read == (models.CameraRead{}) {
+		return nil
+	}
+	return &read
+}
+
+// belowMinConfidence reports whether a reported read confidence is under the minimum.
+// Reads without a confidence are never considered below it.
+func belowMinConfidence(read models.CameraRead, minConfidence float64) bool {
+	return read.Confidence > 0 && read.Confidence < minConfidence
+}
@@ -0,0 +1,27 @@
+package processors
+
+import "fmt"
+
+// DuplicatePolicy decides what happens when an entry arrives for a plate that is already parked.
+type DuplicatePolicy string
+
+const (
+	// KeepLatest overwrites the earlier entry with the new one.
+	KeepLatest DuplicatePolicy = "keep_latest"
+	// KeepFirst ignores the new entry and keeps the earlier one.
+	KeepFirst DuplicatePolicy = "keep_first"
+	// ClosePrevious closes the earlier session as a missed exit before recording the new entry.
+	ClosePrevious DuplicatePolicy = "close_previous"
+)
+
+// ParseDuplicatePolicy validates a policy name; an empty name selects KeepLatest.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(name); policy {
+	case "":
+		return KeepLatest, nil
+	case KeepLatest, KeepFirst, ClosePrevious:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate entry policy %q", name)
+	}
+}
@@ -2,8 +2,11 @@ package processors
 
 import (
 	"encoding/json"
+	"fmt"
 	"go_services/cmd/svc_backend/metrics"
 	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/plates"
+	"go_services/cmd/svc_backend/registry"
 	"time"
 
 	"go_services/pkg/logger"
@@ -13,7 +16,14 @@ import (
 
 // EntryEventProcessor handles the processing of entry events.
 type EntryEventProcessor struct {
-	DataStore DataStore
+	Sessions        SessionStore
+	DuplicatePolicy DuplicatePolicy // defaults to KeepLatest
+	AlertPublisher  AlertPublisher  // optional; receives duplicate entry alerts
+	SummaryPoster   SummaryPoster   // optional; receives sessions closed by ClosePrevious
+	Normalizer      *plates.Normalizer
+	Registry        PlateClassifier // optional; blacklisted entries raise an alert
+	MinConfidence   float64         // reads below it are sent for review instead of being stored
+	ReviewPublisher ReviewPublisher // optional; receives low confidence reads
 }
 
 // ProcessMessage processes an entry event message.
@@ -27,19 +37,61 @@ func (p *EntryEventProcessor) ProcessMessage(msgBody []byte) error {
 
 		return err
 	}
+	rawVehiclePlate := payload.VehiclePlate
+	payload.VehiclePlate = p.Normalizer.Normalize(rawVehiclePlate)
+	if belowMinConfidence(payload.CameraRead, p.MinConfidence) && p.ReviewPublisher != nil {
+		logger.Log.Info().Msgf("Entry read of %s has confidence %.2f, sending for review", payload.VehiclePlate, payload.Confidence)
+		// metrics instrumentation:
+		metrics.LowConfidenceReads.With(prometheus.Labels{"event_type": "entry"}).Inc()
+		if err := p.ReviewPublisher.PublishReview(models.ReviewItem{Reason: models.ReviewReasonLowReadConfidence, EntryEvent: &payload}); err != nil {
+			metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "entry", "error_stage": "review_publish_error"}).Inc()
+			return err
+		}
+		return nil
+	}
+	p.checkBlacklist(payload)
+
+	previousSession, alreadyParked, err := p.Sessions.GetOpenSession(payload.VehiclePlate)
+	if err != nil {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "entry", "error_stage": "db_read_error"}).Inc()
 
-	hashKey := payload.VehiclePlate
-	fieldName := "entry_date_time"
-	fieldValue := payload.EntryDateTime
-	logger.Log.Debug().Msgf("Storing entry: key - %s; field - %s; value - %s", hashKey, fieldName, fieldValue)
+		return err
+	}
+	if alreadyParked {
+		storeEntry, err := p.handleDuplicate(payload, previousSession.EntryDateTime)
+		if err != nil {
+			// metrics instrumentation:
+			metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "entry", "error_stage": "duplicate_entry"}).Inc()
 
-	if err := p.DataStore.AddFieldToHash(hashKey, fieldName, fieldValue); err != nil {
+			return err
+		}
+		if !storeEntry {
+			p.recordSuccess(start)
+			return nil
+		}
+	}
+
+	session := models.Session{
+		VehiclePlate:  payload.VehiclePlate,
+		RawEntryPlate: rawVehiclePlate,
+		EntryDateTime: payload.EntryDateTime,
+		EntryCamera:   cameraRead(payload.CameraRead),
+	}
+	logger.Log.Debug().Msgf("Storing entry: plate - %s; entry - %s", session.VehiclePlate, session.EntryDateTime)
+
+	if err := p.Sessions.OpenSession(session); err != nil {
 		// metrics instrumentation: Increment the error counter for Redis operation error
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "entry", "error_stage": "db_write_error"}).Inc()
 
 		return err
 	}
 
+	p.recordSuccess(start)
+	return nil
+}
+
+func (p *EntryEventProcessor) recordSuccess(start time.Time) {
 	logger.Log.Info().Msg("Process Entry Event Success")
 	// metrics instrumentation: Record the duration taken to process the message
 	duration := time.Since(start).Seconds()
@@ -47,6 +99,63 @@ func (p *EntryEventProcessor) ProcessMessage(msgBody []byte) error {
 
 	// metrics instrumentation:
 	metrics.EventProcessingSuccesses.With(prometheus.Labels{"event_type": "entry"}).Inc()
+}
 
-	return nil
+// handleDuplicate applies the duplicate policy to an entry for a plate that is already parked and
+// reports whether the new entry should be stored.
+func (p *EntryEventProcessor) handleDuplicate(payload models.EntryEvent, previousEntry time.Time) (bool, error) {
+	policy := p.DuplicatePolicy
+	if policy == "" {
+		policy = KeepLatest
+	}
+	logger.Log.Warn().Msgf("Duplicate entry for %s, already parked since %v; applying %s", payload.VehiclePlate, previousEntry, policy)
+	// metrics instrumentation:
+	metrics.DuplicateEntries.With(prometheus.Labels{"policy": string(policy)}).Inc()
+
+	if p.AlertPublisher != nil {
+		alert := models.Alert{
+			Type:          models.AlertTypeDuplicateEntry,
+			VehiclePlate:  payload.VehiclePlate,
+			EntryDateTime: previousEntry,
+			DetectedAt:    time.Now().UTC(),
+			Detail:        fmt.Sprintf("new entry at %v while parked since %v; policy %s", payload.EntryDateTime, previousEntry, policy),
+		}
+		if err := p.AlertPublisher.PublishAlert(alert); err != nil {
+			logger.Log.Error().Err(err).Msg("Failed publishing duplicate entry alert")
+		}
+	}
+
+	switch policy {
+	case KeepFirst:
+		return false, nil
+	case ClosePrevious:
+		if err := closeAsMissedExit(p.Sessions, p.SummaryPoster, payload.VehiclePlate, payload.EntryDateTime); err != nil {
+			return false, err
+		}
+		return true, nil
+	default:
+		return true, nil
+	}
+}
+
+// checkBlacklist raises an alert when a blacklisted plate enters.
+func (p *EntryEventProcessor) checkBlacklist(payload models.EntryEvent) {
+	if p.Registry == nil || p.Registry.Classify(payload.VehiclePlate, payload.EntryDateTime) != registry.Blacklist {
+		return
+	}
+	logger.Log.Warn().Msgf("Blacklisted plate %s entered at %v", payload.VehiclePlate, payload.EntryDateTime)
+	if p.AlertPublisher == nil {
+		return
+	}
+
+	alert := models.Alert{
+		Type:          models.AlertTypeBlacklisted,
+		VehiclePlate:  payload.VehiclePlate,
+		EntryDateTime: payload.EntryDateTime,
+		DetectedAt:    time.Now().UTC(),
+		Detail:        "blacklisted plate entered",
+	}
+	if err := p.AlertPublisher.PublishAlert(alert); err != nil {
+		logger.Log.Error().Err(err).Msg("Failed publishing blacklist alert")
+	}
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"go_services/cmd/svc_backend/metrics"
 	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/sessions"
 	"testing"
 	"time"
 
@@ -75,16 +77,16 @@ func TestEntryEventProcessor_ProcessMessage(t *testing.T) {
 			metrics.EventProcessingFails.Reset()
 			metrics.EventProcessingSuccesses.Reset()
 
-			// Create a mock DataStore
-			mockDataStore := &MockDataStore{
-				AddFieldToHashFunc: func(hashKey string, fieldName string, fieldValue time.Time) error {
+			// Create a mock SessionStore
+			mockSessionStore := &MockSessionStore{
+				OpenSessionFunc: func(session models.Session) error {
 					return tt.mockError
 				},
 			}
 
-			// Create an EntryEventProcessor with the mock DataStore
+			// Create an EntryEventProcessor with the mock SessionStore
 			processor := &EntryEventProcessor{
-				DataStore: mockDataStore,
+				Sessions: mockSessionStore,
 			}
 
 			// Call ProcessMessage with the test message body
@@ -104,3 +106,241 @@ func TestEntryEventProcessor_ProcessMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestEntryEventProcessor_DuplicateEntry(t *testing.T) {
+	previousEntry, _ := time.Parse(time.RFC3339, "2024-09-11T08:00:00Z")
+	newEntry, _ := time.Parse(time.RFC3339, "2024-09-11T10:00:00Z")
+
+	tests := []struct {
+		name              string
+		policy            DuplicatePolicy
+		storedEntry       time.Time
+		storedExit        time.Time
+		expectedOpenEntry time.Time
+		expectedAlert     bool
+		expectedMissed    bool
+		expectedPolicyNo  float64
+	}{
+		{
+			name:              "Not Parked",
+			policy:            KeepFirst,
+			expectedOpenEntry: newEntry,
+		},
+		{
+			name:              "Previous Session Exited",
+			policy:            KeepFirst,
+			storedEntry:       previousEntry,
+			storedExit:        previousEntry.Add(time.Hour),
+			expectedOpenEntry: newEntry,
+		},
+		{
+			name:              "Keep Latest",
+			policy:            KeepLatest,
+			storedEntry:       previousEntry,
+			expectedOpenEntry: newEntry,
+			expectedAlert:     true,
+			expectedPolicyNo:  1,
+		},
+		{
+			name:              "Default Policy Keeps Latest",
+			policy:            "",
+			storedEntry:       previousEntry,
+			expectedOpenEntry: newEntry,
+			expectedAlert:     true,
+			expectedPolicyNo:  1,
+		},
+		{
+			name:              "Keep First",
+			policy:            KeepFirst,
+			storedEntry:       previousEntry,
+			expectedOpenEntry: previousEntry,
+			expectedAlert:     true,
+			expectedPolicyNo:  1,
+		},
+		{
+			name:              "Close Previous",
+			policy:            ClosePrevious,
+			storedEntry:       previousEntry,
+			expectedOpenEntry: newEntry,
+			expectedAlert:     true,
+			expectedMissed:    true,
+			expectedPolicyNo:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics.DuplicateEntries.Reset()
+
+			store := sessions.NewMemoryStore()
+			if !tt.storedEntry.IsZero() {
+				assert.NoError(t, store.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: tt.storedEntry}))
+			}
+			if !tt.storedExit.IsZero() {
+				_, err := store.CloseSession("ABC123", tt.storedExit)
+				assert.NoError(t, err)
+			}
+			alerts := &MockAlertPublisher{}
+			var posted []models.ParkingLog
+
+			processor := &EntryEventProcessor{
+				Sessions:        store,
+				DuplicatePolicy: tt.policy,
+				AlertPublisher:  alerts,
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = append(posted, data.(models.ParkingLog))
+						return nil
+					},
+				},
+			}
+
+			msgBody, _ := json.Marshal(models.EntryEvent{VehiclePlate: "ABC123", EntryDateTime: newEntry})
+			err := processor.ProcessMessage(msgBody)
+
+			assert.NoError(t, err)
+			openSession, open, err := store.GetOpenSession("ABC123")
+			assert.NoError(t, err)
+			assert.True(t, open)
+			assert.Equal(t, tt.expectedOpenEntry, openSession.EntryDateTime)
+			if tt.expectedAlert {
+				assert.Len(t, alerts.Alerts, 1)
+				assert.Equal(t, models.AlertTypeDuplicateEntry, alerts.Alerts[0].Type)
+				assert.Equal(t, previousEntry, alerts.Alerts[0].EntryDateTime)
+			} else {
+				assert.Empty(t, alerts.Alerts)
+			}
+			if tt.expectedMissed {
+				assert.Len(t, posted, 1)
+				assert.Equal(t, models.StatusMissedExit, posted[0].Status)
+				assert.Equal(t, previousEntry, posted[0].EntryDateTime)
+				assert.Equal(t, newEntry, posted[0].ExitDateTime)
+			} else {
+				assert.Empty(t, posted)
+			}
+			policy, _ := ParseDuplicatePolicy(string(tt.policy))
+			count := testutil.ToFloat64(metrics.DuplicateEntries.With(prometheus.Labels{"policy": string(policy)}))
+			assert.Equal(t, tt.expectedPolicyNo, count)
+		})
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	policy, err := ParseDuplicatePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, KeepLatest, policy)
+
+	policy, err = ParseDuplicatePolicy("close_previous")
+	assert.NoError(t, err)
+	assert.Equal(t, ClosePrevious, policy)
+
+	_, err = ParseDuplicatePolicy("keep_all")
+	assert.Error(t, err)
+}
+
+func TestEntryEventProcessor_NormalizesPlate(t *testing.T) {
+	var storedKey string
+	processor := &EntryEventProcessor{
+		Sessions: &MockSessionStore{
+			OpenSessionFunc: func(session models.Session) error {
+				storedKey = session.VehiclePlate
+				return nil
+			},
+		},
+	}
+
+	msgBody, _ := json.Marshal(models.EntryEvent{VehiclePlate: "abc-123", EntryDateTime: time.Now()})
+	err := processor.ProcessMessage(msgBody)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC123", storedKey)
+}
+
+func TestEntryEventProcessor_BlacklistAlert(t *testing.T) {
+	classifier := &MockPlateClassifier{Classes: map[string]registry.Class{"STOLEN1": registry.Blacklist}}
+
+	for _, plate := range []string{"STOLEN1", "VISITOR1"} {
+		t.Run(plate, func(t *testing.T) {
+			alerts := &MockAlertPublisher{}
+			processor := &EntryEventProcessor{
+				Sessions:       &MockSessionStore{},
+				AlertPublisher: alerts,
+				Registry:       classifier,
+			}
+
+			msgBody, _ := json.Marshal(models.EntryEvent{VehiclePlate: plate, EntryDateTime: time.Now()})
+			err := processor.ProcessMessage(msgBody)
+
+			assert.NoError(t, err)
+			if plate == "STOLEN1" {
+				assert.Len(t, alerts.Alerts, 1)
+				assert.Equal(t, models.AlertTypeBlacklisted, alerts.Alerts[0].Type)
+				assert.Equal(t, plate, alerts.Alerts[0].VehiclePlate)
+			} else {
+				assert.Empty(t, alerts.Alerts)
+			}
+		})
+	}
+}
+
+func TestEntryEventProcessor_CameraRead(t *testing.T) {
+	tests := []struct {
+		name             string
+		read             models.CameraRead
+		expectedReviewed bool
+		expectedStored   bool
+		expectedCamera   *models.CameraRead
+	}{
+		{
+			name:           "Stored With Entry",
+			read:           models.CameraRead{Confidence: 0.95, CameraID: "gate-1", SnapshotURI: "s3://snapshots/1.jpg"},
+			expectedStored: true,
+			expectedCamera: &models.CameraRead{Confidence: 0.95, CameraID: "gate-1", SnapshotURI: "s3://snapshots/1.jpg"},
+		},
+		{
+			name:           "No Camera Details",
+			read:           models.CameraRead{},
+			expectedStored: true,
+		},
+		{
+			name:             "Below Minimum Confidence",
+			read:             models.CameraRead{Confidence: 0.4, CameraID: "gate-1"},
+			expectedReviewed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored []models.Session
+			reviews := &MockReviewPublisher{}
+			processor := &EntryEventProcessor{
+				Sessions: &MockSessionStore{
+					OpenSessionFunc: func(session models.Session) error {
+						stored = append(stored, session)
+						return nil
+					},
+				},
+				MinConfidence:   0.7,
+				ReviewPublisher: reviews,
+			}
+
+			msgBody, _ := json.Marshal(models.EntryEvent{VehiclePlate: "ABC123", EntryDateTime: time.Now(), CameraRead: tt.read})
+			err := processor.ProcessMessage(msgBody)
+
+			assert.NoError(t, err)
+			if tt.expectedStored {
+				assert.Len(t, stored, 1)
+				assert.Equal(t, tt.expectedCamera, stored[0].EntryCamera)
+			} else {
+				assert.Empty(t, stored)
+			}
+			if tt.expectedReviewed {
+				assert.Len(t, reviews.Items, 1)
+				assert.Equal(t, models.ReviewReasonLowReadConfidence, reviews.Items[0].Reason)
+				assert.Equal(t, tt.read, reviews.Items[0].EntryEvent.CameraRead)
+			} else {
+				assert.Empty(t, reviews.Items)
+			}
+		})
+	}
+}
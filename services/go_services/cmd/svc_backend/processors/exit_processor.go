@@ -2,9 +2,14 @@ package processors
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go_services/cmd/svc_backend/matching"
 	"go_services/cmd/svc_backend/metrics"
 	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/plates"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/sessions"
 	"go_services/pkg/logger"
 	"time"
 
@@ -13,8 +18,18 @@ import (
 
 // ExitEventProcessor handles the processing of exit events.
 type ExitEventProcessor struct {
-	DataStore     DataStore
-	SummaryPoster SummaryPoster
+	Sessions       SessionStore
+	SummaryPoster  SummaryPoster
+	Tariff         Tariff
+	OrphanRecorder OrphanRecorder // optional; records exits that have no matching entry
+	Normalizer     *plates.Normalizer
+	Registry       PlateClassifier // optional; permit and whitelist holders are not charged
+	MinConfidence  float64         // reads below it are sent for review instead of being billed
+	Validations    ValidationStore // optional; validations attached during the session are applied to the fee
+
+	// optional near matching of exits without an exact entry against open sessions
+	PlateMatcher    *matching.Matcher
+	ReviewPublisher ReviewPublisher
 }
 
 // ProcessMessage processes an exit event message.
@@ -28,36 +43,79 @@ func (p *ExitEventProcessor) ProcessMessage(msgBody []byte) error {
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "json_unmarshal"}).Inc()
 		return err
 	}
+	rawVehiclePlate := payload.VehiclePlate
+	payload.VehiclePlate = p.Normalizer.Normalize(rawVehiclePlate)
+	if belowMinConfidence(payload.CameraRead, p.MinConfidence) && p.ReviewPublisher != nil {
+		logger.Log.Info().Msgf("Exit read of %s has confidence %.2f, sending for review", payload.VehiclePlate, payload.Confidence)
+		// metrics instrumentation:
+		metrics.LowConfidenceReads.With(prometheus.Labels{"event_type": "exit"}).Inc()
+		if err := p.ReviewPublisher.PublishReview(models.ReviewItem{Reason: models.ReviewReasonLowReadConfidence, ExitEvent: &payload}); err != nil {
+			metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "review_publish_error"}).Inc()
+			return err
+		}
+		return nil
+	}
 
-	hashKey := payload.VehiclePlate
-	fieldName := "exit_date_time"
-	fieldValue := payload.ExitDateTime
-	logger.Log.Debug().Msgf("Storing exit: key - %s; field - %s; value - %s", hashKey, fieldName, fieldValue)
+	logger.Log.Debug().Msgf("Storing exit: plate - %s; exit - %s", payload.VehiclePlate, payload.ExitDateTime)
 
-	// Store the exit time
-	if err := p.DataStore.AddFieldToHash(hashKey, fieldName, fieldValue); err != nil {
+	// Store the exit time and read the session it closes in one atomic step
+	session, err := p.Sessions.CloseSession(payload.VehiclePlate, payload.ExitDateTime)
+	if errors.Is(err, sessions.ErrSessionWrite) {
 		logger.Log.Error().Err(err).Msg("Failed writing to datastore")
 		// metrics instrumentation:
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "db_write_error"}).Inc()
 		return err
 	}
 
-	fieldName = "entry_date_time"
-	layout := time.RFC3339
-	entryDateTime, err := p.DataStore.GetFieldAsTime(payload.VehiclePlate, fieldName, layout)
+	// An exit processed again, e.g. after posting its summary failed, finds the session it closed
+	if errors.Is(err, sessions.ErrNoOpenSession) {
+		closed, ok, closedErr := p.Sessions.GetClosedSession(payload.VehiclePlate, payload.ExitDateTime)
+		if closedErr != nil {
+			// metrics instrumentation:
+			metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "db_read_error"}).Inc()
+			return fmt.Errorf("error retrieving closed session: %w", closedErr)
+		}
+		if ok {
+			logger.Log.Info().Msgf("Exit of %s at %s already closed its session, posting the summary again", payload.VehiclePlate, payload.ExitDateTime)
+			session, err = closed, nil
+		}
+	}
+
+	var match *matching.Match
+	if errors.Is(err, sessions.ErrNoOpenSession) && p.PlateMatcher != nil {
+		match, session, err = p.matchOpenSession(payload)
+		if errors.Is(err, errSentForReview) {
+			logger.Log.Info().Msg("Process Exit Event sent for review")
+			return nil
+		}
+	}
 	if err != nil {
+		if errors.Is(err, sessions.ErrNoOpenSession) && p.OrphanRecorder != nil {
+			if recordErr := p.OrphanRecorder.RecordOrphanExit(payload); recordErr != nil {
+				logger.Log.Error().Err(recordErr).Msg("Failed recording orphan exit")
+			}
+		}
 		// metrics instrumentation:
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "db_read_error"}).Inc()
-		return fmt.Errorf("error retrieving entry time: %v", err)
+		return fmt.Errorf("error retrieving entry time: %w", err)
 	}
 
-	// Generate the parking summary
-	parkingLog, err := GenerateParkingSummary(payload.VehiclePlate, payload.ExitDateTime, entryDateTime)
+	// Generate the parking summary, billed to the entry plate when the exit was near matched
+	parkingLog, err := GenerateParkingSummary(session.VehiclePlate, payload.ExitDateTime, session.EntryDateTime)
 	if err != nil {
 		// metrics instrumentation:
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "generate_summary"}).Inc()
 		return err
 	}
+	if match != nil {
+		parkingLog.MatchedExitPlate = payload.VehiclePlate
+		parkingLog.MatchConfidence = match.Confidence
+	}
+	parkingLog.RawVehiclePlate = rawVehiclePlate
+	parkingLog.RawEntryPlate = session.RawEntryPlate
+	parkingLog.ExitCamera = cameraRead(payload.CameraRead)
+	parkingLog.EntryCamera = session.EntryCamera
+	validationsStored := p.applyFee(parkingLog)
 
 	// Post the parking summary to the API
 	if err := p.SummaryPoster.PostSummary(*parkingLog); err != nil {
@@ -65,6 +123,10 @@ func (p *ExitEventProcessor) ProcessMessage(msgBody []byte) error {
 		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "exit", "error_stage": "api_post_error"}).Inc()
 		return err
 	}
+	// Validations are used up only once the fee they reduced was posted
+	if validationsStored {
+		clearValidations(p.Validations, parkingLog.VehiclePlate)
+	}
 
 	logger.Log.Info().Msg("Process Exit Event Success")
 	// metrics instrumentation: Record the duration taken to process the message
@@ -76,3 +138,32 @@ func (p *ExitEventProcessor) ProcessMessage(msgBody []byte) error {
 
 	return nil
 }
+
+// applyFee classifies the plate and sets the fee of the parking log, applying any validations
+// attached during the session, and reports whether the plate's stored validations are to be cleared
+// after posting. Permit and whitelist plates park free and leave their validations unused.
+func (p *ExitEventProcessor) applyFee(parkingLog *models.ParkingLog) bool {
+	class := registry.Visitor
+	if p.Registry != nil {
+		class = p.Registry.Classify(parkingLog.VehiclePlate, parkingLog.ExitDateTime)
+	}
+	parkingLog.Classification = string(class)
+
+	if class == registry.Permit || class == registry.Whitelist {
+		parkingLog.FeeCents = 0
+		return false
+	}
+
+	var validations []models.ValidationEvent
+	stored := false
+	if p.Validations != nil {
+		var err error
+		validations, stored, err = sessionValidations(p.Validations, parkingLog.VehiclePlate, parkingLog.EntryDateTime, parkingLog.ExitDateTime)
+		if err != nil {
+			logger.Log.Error().Err(err).Msg("Failed reading validations, charging the full fee")
+		}
+	}
+	stay := parkingLog.ExitDateTime.Sub(parkingLog.EntryDateTime)
+	parkingLog.FeeCents, parkingLog.Discounts = p.Tariff.FeeWithValidations(stay, validations)
+	return stored
+}
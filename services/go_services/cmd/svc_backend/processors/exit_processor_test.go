@@ -3,11 +3,17 @@ package processors
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
 	"time"
 
+	"go_services/cmd/svc_backend/matching"
 	"go_services/cmd/svc_backend/metrics"
 	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/plates"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/sessions"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -21,10 +27,20 @@ import (
 //		logger.Log = zerolog.New(os.Stderr).With().Timestamp().Logger()
 //		zerolog.SetGlobalLevel(zerolog.DebugLevel) // Set the logging level to Debug
 //	}
+//
+// closingSessionStore returns a mock store in which every exit closes a session entered at entryDateTime.
+func closingSessionStore(entryDateTime time.Time) *MockSessionStore {
+	return &MockSessionStore{
+		CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+			return models.Session{VehiclePlate: vehiclePlate, EntryDateTime: entryDateTime, ExitDateTime: exitDateTime}, nil
+		},
+	}
+}
+
 func TestExitEventProcessor(t *testing.T) {
 	testCases := []struct {
 		name                 string
-		mockDataStore        *MockDataStore
+		mockSessionStore     *MockSessionStore
 		mockSummaryPoster    *MockSummaryPoster
 		msgBody              []byte
 		expectedError        bool
@@ -34,15 +50,8 @@ func TestExitEventProcessor(t *testing.T) {
 		errorStage           string
 	}{
 		{
-			name: "Success",
-			mockDataStore: &MockDataStore{
-				AddFieldToHashFunc: func(key, field string, value time.Time) error {
-					return nil
-				},
-				GetFieldAsTimeFunc: func(key, field, layout string) (time.Time, error) {
-					return time.Now().Add(-1 * time.Hour), nil
-				},
-			},
+			name:             "Success",
+			mockSessionStore: closingSessionStore(time.Now().Add(-1 * time.Hour)),
 			mockSummaryPoster: &MockSummaryPoster{
 				PostSummaryFunc: func(data interface{}) error {
 					return nil
@@ -64,7 +73,7 @@ func TestExitEventProcessor(t *testing.T) {
 		},
 		{
 			name:                 "FailureOnUnmarshal",
-			mockDataStore:        &MockDataStore{},
+			mockSessionStore:     &MockSessionStore{},
 			mockSummaryPoster:    &MockSummaryPoster{},
 			msgBody:              []byte(`{invalid json}`),
 			expectedError:        true,
@@ -75,12 +84,9 @@ func TestExitEventProcessor(t *testing.T) {
 		},
 		{
 			name: "FailureOnDBWrite",
-			mockDataStore: &MockDataStore{
-				AddFieldToHashFunc: func(key, field string, value time.Time) error {
-					return errors.New("DB write error")
-				},
-				GetFieldAsTimeFunc: func(key, field, layout string) (time.Time, error) {
-					return time.Time{}, nil
+			mockSessionStore: &MockSessionStore{
+				CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+					return models.Session{}, fmt.Errorf("%w: DB write error", sessions.ErrSessionWrite)
 				},
 			},
 			mockSummaryPoster: &MockSummaryPoster{},
@@ -100,12 +106,9 @@ func TestExitEventProcessor(t *testing.T) {
 		},
 		{
 			name: "FailureOnDBRead",
-			mockDataStore: &MockDataStore{
-				AddFieldToHashFunc: func(key, field string, value time.Time) error {
-					return nil
-				},
-				GetFieldAsTimeFunc: func(key, field, layout string) (time.Time, error) {
-					return time.Time{}, errors.New("entry time retrieval error")
+			mockSessionStore: &MockSessionStore{
+				CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+					return models.Session{}, errors.New("entry time retrieval error")
 				},
 			},
 			mockSummaryPoster: &MockSummaryPoster{},
@@ -124,15 +127,26 @@ func TestExitEventProcessor(t *testing.T) {
 			errorStage:           "db_read_error",
 		},
 		{
-			name: "FailureOnPostSummary",
-			mockDataStore: &MockDataStore{
-				AddFieldToHashFunc: func(key, field string, value time.Time) error {
-					return nil
-				},
-				GetFieldAsTimeFunc: func(key, field, layout string) (time.Time, error) {
-					return time.Now().Add(-1 * time.Hour), nil
-				},
-			},
+			name:              "NoOpenSession",
+			mockSessionStore:  &MockSessionStore{},
+			mockSummaryPoster: &MockSummaryPoster{},
+			msgBody: func() []byte {
+				payload := models.ExitEvent{
+					VehiclePlate: "ABC123",
+					ExitDateTime: time.Now(),
+				}
+				data, _ := json.Marshal(payload)
+				return data
+			}(),
+			expectedError:        true,
+			expectedErrorMessage: "no open session",
+			expectedSuccessCount: 0,
+			expectedFailCount:    1,
+			errorStage:           "db_read_error",
+		},
+		{
+			name:             "FailureOnPostSummary",
+			mockSessionStore: closingSessionStore(time.Now().Add(-1 * time.Hour)),
 			mockSummaryPoster: &MockSummaryPoster{
 				PostSummaryFunc: func(data interface{}) error {
 					return errors.New("post summary error")
@@ -161,7 +175,7 @@ func TestExitEventProcessor(t *testing.T) {
 			metrics.EventProcessingLatency.Reset()
 
 			processor := ExitEventProcessor{
-				DataStore:     testCase.mockDataStore,
+				Sessions:      testCase.mockSessionStore,
 				SummaryPoster: testCase.mockSummaryPoster,
 			}
 
@@ -184,3 +198,440 @@ func TestExitEventProcessor(t *testing.T) {
 		})
 	}
 }
+
+func TestExitEventProcessor_OrphanExit(t *testing.T) {
+	var recorded []models.ExitEvent
+	processor := ExitEventProcessor{
+		Sessions:      &MockSessionStore{},
+		SummaryPoster: &MockSummaryPoster{},
+		OrphanRecorder: &MockOrphanRecorder{
+			RecordOrphanExitFunc: func(event models.ExitEvent) error {
+				recorded = append(recorded, event)
+				return nil
+			},
+		},
+	}
+
+	msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "NOENTRY1", ExitDateTime: time.Now()})
+	err := processor.ProcessMessage(msgBody)
+
+	assert.ErrorIs(t, err, sessions.ErrNoOpenSession)
+	assert.Len(t, recorded, 1)
+	assert.Equal(t, "NOENTRY1", recorded[0].VehiclePlate)
+}
+
+func TestExitEventProcessor_ProcessedAgain(t *testing.T) {
+	store := sessions.NewMemoryStore()
+	entryDateTime := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: entryDateTime}))
+
+	var posted []models.ParkingLog
+	var orphans int
+	postErr := errors.New("api unavailable")
+	processor := ExitEventProcessor{
+		Sessions: store,
+		SummaryPoster: &MockSummaryPoster{
+			PostSummaryFunc: func(data interface{}) error {
+				if postErr != nil {
+					return postErr
+				}
+				posted = append(posted, data.(models.ParkingLog))
+				return nil
+			},
+		},
+		OrphanRecorder: &MockOrphanRecorder{
+			RecordOrphanExitFunc: func(event models.ExitEvent) error {
+				orphans++
+				return nil
+			},
+		},
+	}
+
+	msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: entryDateTime.Add(time.Hour)})
+	assert.ErrorIs(t, processor.ProcessMessage(msgBody), postErr)
+
+	// the retried exit finds the session it closed and posts its summary
+	postErr = nil
+	assert.NoError(t, processor.ProcessMessage(msgBody))
+	assert.Len(t, posted, 1)
+	assert.Equal(t, entryDateTime, posted[0].EntryDateTime)
+	assert.Zero(t, orphans)
+
+	// a later exit of the closed session is still an orphan
+	msgBody, _ = json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: entryDateTime.Add(2 * time.Hour)})
+	assert.ErrorIs(t, processor.ProcessMessage(msgBody), sessions.ErrNoOpenSession)
+	assert.Equal(t, 1, orphans)
+}
+
+func TestExitEventProcessor_AppliesTariff(t *testing.T) {
+	exitDateTime := time.Now()
+	var posted models.ParkingLog
+	processor := ExitEventProcessor{
+		Sessions: closingSessionStore(exitDateTime.Add(-90 * time.Minute)),
+		SummaryPoster: &MockSummaryPoster{
+			PostSummaryFunc: func(data interface{}) error {
+				posted = data.(models.ParkingLog)
+				return nil
+			},
+		},
+		Tariff: Tariff{HourlyRateCents: 250},
+	}
+
+	msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: exitDateTime})
+	err := processor.ProcessMessage(msgBody)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), posted.FeeCents) // two started hours
+}
+
+func TestExitEventProcessor_PlateMatching(t *testing.T) {
+	exitDateTime, _ := time.Parse(time.RFC3339, "2024-09-11T12:00:00Z")
+	openEntry, _ := time.Parse(time.RFC3339, "2024-09-11T10:00:00Z")
+
+	testCases := []struct {
+		name               string
+		exitPlate          string
+		expectedError      bool
+		expectedPostPlate  string
+		expectedClosed     string
+		expectedReviewed   bool
+		expectedReason     string
+		expectedReviewOf   string
+		expectedOrphan     bool
+		expectedConfidence float64
+	}{
+		{
+			name:               "ConfidentMatch",
+			exitPlate:          "A8C123",
+			expectedPostPlate:  "ABC123",
+			expectedClosed:     "ABC123",
+			expectedConfidence: 1 - 0.2/6,
+		},
+		{
+			name:             "LowConfidenceMatch",
+			exitPlate:        "ABC124",
+			expectedReviewed: true,
+			expectedReason:   models.ReviewReasonLowConfidenceMatch,
+			expectedReviewOf: "ABC123",
+		},
+		{
+			name:             "AmbiguousMatch",
+			exitPlate:        "LMN45D",
+			expectedReviewed: true,
+			expectedReason:   models.ReviewReasonAmbiguousMatch,
+			expectedReviewOf: "LMN450",
+		},
+		{
+			name:           "NoMatch",
+			exitPlate:      "QWE456",
+			expectedError:  true,
+			expectedOrphan: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(tContext *testing.T) {
+			store := sessions.NewMemoryStore()
+			for _, plate := range []string{"ABC123", "XYZ789", "LMN456", "ABC124XYZ", "LMN450", "LMN45O"} {
+				assert.NoError(tContext, store.OpenSession(models.Session{VehiclePlate: plate, EntryDateTime: openEntry}))
+			}
+			store.CloseSession("XYZ789", openEntry.Add(time.Hour))
+			store.CloseSession("A8C123", exitDateTime) // exit without an entry
+			openBefore, _ := store.ListOpen()
+
+			var posted []models.ParkingLog
+			var orphans []models.ExitEvent
+			reviews := &MockReviewPublisher{}
+
+			processor := ExitEventProcessor{
+				Sessions: store,
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = append(posted, data.(models.ParkingLog))
+						return nil
+					},
+				},
+				OrphanRecorder: &MockOrphanRecorder{
+					RecordOrphanExitFunc: func(event models.ExitEvent) error {
+						orphans = append(orphans, event)
+						return nil
+					},
+				},
+				PlateMatcher:    &matching.Matcher{AcceptThreshold: 0.9, ReviewThreshold: 0.75},
+				ReviewPublisher: reviews,
+			}
+
+			msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: testCase.exitPlate, ExitDateTime: exitDateTime})
+			processError := processor.ProcessMessage(msgBody)
+
+			if testCase.expectedError {
+				assert.ErrorIs(tContext, processError, sessions.ErrNoOpenSession)
+			} else {
+				assert.NoError(tContext, processError)
+			}
+			openAfter, _ := store.ListOpen()
+			closed := ""
+			for _, session := range openBefore {
+				if !slices.ContainsFunc(openAfter, func(s models.Session) bool { return s.VehiclePlate == session.VehiclePlate }) {
+					closed = session.VehiclePlate
+				}
+			}
+			assert.Equal(tContext, testCase.expectedClosed, closed)
+
+			if testCase.expectedPostPlate != "" {
+				assert.Len(tContext, posted, 1)
+				assert.Equal(tContext, testCase.expectedPostPlate, posted[0].VehiclePlate)
+				assert.Equal(tContext, testCase.exitPlate, posted[0].MatchedExitPlate)
+				assert.InDelta(tContext, testCase.expectedConfidence, posted[0].MatchConfidence, 1e-9)
+				assert.Equal(tContext, "2h0m0s", posted[0].Duration)
+			} else {
+				assert.Empty(tContext, posted)
+			}
+
+			if testCase.expectedReviewed {
+				assert.Len(tContext, reviews.Items, 1)
+				assert.Equal(tContext, testCase.expectedReason, reviews.Items[0].Reason)
+				assert.Equal(tContext, testCase.expectedReviewOf, reviews.Items[0].CandidatePlate)
+				if assert.NotNil(tContext, reviews.Items[0].CandidateEntry) {
+					assert.Equal(tContext, openEntry, *reviews.Items[0].CandidateEntry)
+				}
+			} else {
+				assert.Empty(tContext, reviews.Items)
+			}
+			assert.Equal(tContext, testCase.expectedOrphan, len(orphans) == 1)
+		})
+	}
+}
+
+func TestExitEventProcessor_NormalizesPlate(t *testing.T) {
+	normalizer, _ := plates.NewNormalizer([]plates.Rule{{Country: "FI", Pattern: `^([A-Z]{2,3})([0-9]{1,3})$`, Format: "$1-$2"}})
+	exitDateTime := time.Now()
+	var keys []string
+	var posted models.ParkingLog
+	processor := ExitEventProcessor{
+		Sessions: &MockSessionStore{
+			CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+				keys = append(keys, vehiclePlate)
+				return models.Session{VehiclePlate: vehiclePlate, EntryDateTime: exitDateTime.Add(-time.Hour)}, nil
+			},
+		},
+		SummaryPoster: &MockSummaryPoster{
+			PostSummaryFunc: func(data interface{}) error {
+				posted = data.(models.ParkingLog)
+				return nil
+			},
+		},
+		Normalizer: normalizer,
+	}
+
+	msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "abc 123", ExitDateTime: exitDateTime})
+	err := processor.ProcessMessage(msgBody)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ABC-123"}, keys)
+	assert.Equal(t, "ABC-123", posted.VehiclePlate)
+	assert.Equal(t, "abc 123", posted.RawVehiclePlate)
+}
+
+func TestExitEventProcessor_Classification(t *testing.T) {
+	classifier := &MockPlateClassifier{Classes: map[string]registry.Class{
+		"PERMIT1": registry.Permit,
+		"STAFF1":  registry.Whitelist,
+		"STOLEN1": registry.Blacklist,
+	}}
+
+	testCases := []struct {
+		plate                  string
+		expectedClassification string
+		expectedFee            int64
+	}{
+		{"VISITOR1", "visitor", 500},
+		{"PERMIT1", "permit", 0},
+		{"STAFF1", "whitelist", 0},
+		{"STOLEN1", "blacklist", 500},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.plate, func(tContext *testing.T) {
+			exitDateTime := time.Now()
+			var posted models.ParkingLog
+			processor := ExitEventProcessor{
+				Sessions: closingSessionStore(exitDateTime.Add(-90 * time.Minute)),
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = data.(models.ParkingLog)
+						return nil
+					},
+				},
+				Tariff:   Tariff{HourlyRateCents: 250},
+				Registry: classifier,
+			}
+
+			msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: testCase.plate, ExitDateTime: exitDateTime})
+			err := processor.ProcessMessage(msgBody)
+
+			assert.NoError(tContext, err)
+			assert.Equal(tContext, testCase.expectedClassification, posted.Classification)
+			assert.Equal(tContext, testCase.expectedFee, posted.FeeCents)
+		})
+	}
+}
+
+func TestExitEventProcessor_CameraRead(t *testing.T) {
+	exitRead := models.CameraRead{Confidence: 0.9, CameraID: "gate-2", SnapshotURI: "s3://snapshots/2.jpg"}
+
+	testCases := []struct {
+		name                string
+		exitRead            models.CameraRead
+		entryCamera         *models.CameraRead
+		expectedEntryCamera *models.CameraRead
+		expectedExitCamera  *models.CameraRead
+		expectedReviewed    bool
+	}{
+		{
+			name:                "BothReads",
+			exitRead:            exitRead,
+			entryCamera:         &models.CameraRead{Confidence: 0.95, CameraID: "gate-1", SnapshotURI: "s3://snapshots/1.jpg"},
+			expectedEntryCamera: &models.CameraRead{Confidence: 0.95, CameraID: "gate-1", SnapshotURI: "s3://snapshots/1.jpg"},
+			expectedExitCamera:  &exitRead,
+		},
+		{
+			name: "NoReads",
+		},
+		{
+			name:             "BelowMinimumConfidence",
+			exitRead:         models.CameraRead{Confidence: 0.5},
+			expectedReviewed: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(tContext *testing.T) {
+			exitDateTime := time.Now()
+			var posted []models.ParkingLog
+			reviews := &MockReviewPublisher{}
+			processor := ExitEventProcessor{
+				Sessions: &MockSessionStore{
+					CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+						return models.Session{VehiclePlate: vehiclePlate, EntryDateTime: exitDateTime.Add(-time.Hour), EntryCamera: testCase.entryCamera}, nil
+					},
+				},
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = append(posted, data.(models.ParkingLog))
+						return nil
+					},
+				},
+				MinConfidence:   0.7,
+				ReviewPublisher: reviews,
+			}
+
+			msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: exitDateTime, CameraRead: testCase.exitRead})
+			err := processor.ProcessMessage(msgBody)
+
+			assert.NoError(tContext, err)
+			if testCase.expectedReviewed {
+				assert.Empty(tContext, posted)
+				assert.Len(tContext, reviews.Items, 1)
+				assert.Equal(tContext, models.ReviewReasonLowReadConfidence, reviews.Items[0].Reason)
+				return
+			}
+			assert.Len(tContext, posted, 1)
+			assert.Equal(tContext, testCase.expectedEntryCamera, posted[0].EntryCamera)
+			assert.Equal(tContext, testCase.expectedExitCamera, posted[0].ExitCamera)
+		})
+	}
+}
+
+func TestExitEventProcessor_AppliesValidations(t *testing.T) {
+	exitDateTime, _ := time.Parse(time.RFC3339, "2024-09-11T13:30:00Z")
+	entryDateTime := exitDateTime.Add(-3*time.Hour - 30*time.Minute)
+	validation := func(id string, kind string, value float64, issuedAt time.Time) string {
+		data, _ := json.Marshal(models.ValidationEvent{ID: id, VehiclePlate: "ABC123", Kind: kind, Value: value, IssuedAt: issuedAt})
+		return string(data)
+	}
+	store := &MockValidationStore{Lists: map[string][]string{
+		"validations:{ABC123}": {
+			validation("stale", models.ValidationPercentOff, 100, entryDateTime.Add(-time.Hour)),
+			validation("v1", models.ValidationFreeMinutes, 120, entryDateTime.Add(time.Hour)),
+		},
+	}}
+
+	var posted models.ParkingLog
+	processor := ExitEventProcessor{
+		Sessions: closingSessionStore(entryDateTime),
+		SummaryPoster: &MockSummaryPoster{
+			PostSummaryFunc: func(data interface{}) error {
+				posted = data.(models.ParkingLog)
+				return nil
+			},
+		},
+		Tariff:      Tariff{HourlyRateCents: 200},
+		Validations: store,
+	}
+
+	msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: exitDateTime})
+	err := processor.ProcessMessage(msgBody)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), posted.FeeCents)
+	assert.Equal(t, []models.AppliedDiscount{{ValidationID: "v1", Description: "120 free minutes", AmountCents: 400}}, posted.Discounts)
+	assert.Empty(t, store.Lists, "used validations are removed")
+}
+
+func TestExitEventProcessor_KeepsUnusedValidations(t *testing.T) {
+	exitDateTime, _ := time.Parse(time.RFC3339, "2024-09-11T13:30:00Z")
+	entryDateTime := exitDateTime.Add(-3*time.Hour - 30*time.Minute)
+	voucher, _ := json.Marshal(models.ValidationEvent{ID: "v1", VehiclePlate: "ABC123", Kind: models.ValidationFreeMinutes, Value: 60, IssuedAt: entryDateTime.Add(time.Hour)})
+
+	testCases := []struct {
+		name             string
+		storedVouchers   int
+		class            registry.Class
+		postError        error
+		expectedError    bool
+		expectedFeeCents int64
+		expectedKept     bool
+	}{
+		{name: "PostFails", storedVouchers: 1, postError: errors.New("api down"), expectedError: true, expectedFeeCents: 600, expectedKept: true},
+		{name: "Permit", storedVouchers: 1, class: registry.Permit, expectedFeeCents: 0, expectedKept: true},
+		{name: "RedeliveredVoucherAppliedOnce", storedVouchers: 2, expectedFeeCents: 600},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(tContext *testing.T) {
+			store := &MockValidationStore{}
+			for i := 0; i < testCase.storedVouchers; i++ {
+				store.AppendToList("validations:{ABC123}", string(voucher))
+			}
+			var posted models.ParkingLog
+			classes := map[string]registry.Class{}
+			if testCase.class != "" {
+				classes["ABC123"] = testCase.class
+			}
+			processor := ExitEventProcessor{
+				Sessions: closingSessionStore(entryDateTime),
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = data.(models.ParkingLog)
+						return testCase.postError
+					},
+				},
+				Tariff:      Tariff{HourlyRateCents: 200},
+				Registry:    &MockPlateClassifier{Classes: classes},
+				Validations: store,
+			}
+
+			msgBody, _ := json.Marshal(models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: exitDateTime})
+			err := processor.ProcessMessage(msgBody)
+
+			if testCase.expectedError {
+				assert.Error(tContext, err)
+			} else {
+				assert.NoError(tContext, err)
+			}
+			assert.Equal(tContext, testCase.expectedFeeCents, posted.FeeCents)
+			assert.Equal(tContext, testCase.expectedKept, len(store.Lists["validations:{ABC123}"]) > 0)
+		})
+	}
+}
@@ -1,14 +1,61 @@
 package processors
 
-import "time"
+import (
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/registry"
+	"time"
+)
 
-// DataStore defines the interface for adding and retrieving key value pairs
-type DataStore interface {
-	AddFieldToHash(hashKey string, fieldName string, fieldValue time.Time) error
-	GetFieldAsTime(hashKey string, fieldName string, layout string) (time.Time, error)
+// SessionStore defines the interface for storing parking sessions.
+type SessionStore interface {
+	// OpenSession records the entry of a session, replacing any earlier entry of the plate.
+	OpenSession(session models.Session) error
+	// CloseSession atomically writes the exit time and returns the session it closes; the error wraps
+	// sessions.ErrNoOpenSession when the plate has no open session and sessions.ErrSessionWrite when
+	// writing the exit failed. The exit of a plate that never entered is kept and closes the entry
+	// that arrives after it.
+	CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error)
+	GetOpenSession(vehiclePlate string) (models.Session, bool, error)
+	// GetClosedSession returns the plate's session if it was closed by the exit at exitDateTime, so
+	// that an exit processed again finds the session it closed before.
+	GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error)
+	ListOpen() ([]models.Session, error)
 }
 
 // SummaryPoster defines the interface for posting summaries.
 type SummaryPoster interface {
 	PostSummary(data interface{}) error
 }
+
+// OrphanRecorder defines the interface for recording exit events without a matching entry.
+type OrphanRecorder interface {
+	RecordOrphanExit(event models.ExitEvent) error
+}
+
+// AlertPublisher defines the interface for publishing alerts.
+type AlertPublisher interface {
+	PublishAlert(alert models.Alert) error
+}
+
+// ReviewPublisher defines the interface for routing events to manual review.
+type ReviewPublisher interface {
+	PublishReview(item models.ReviewItem) error
+}
+
+// PlateClassifier defines the interface for looking up the billing class of a plate.
+type PlateClassifier interface {
+	Classify(vehiclePlate string, at time.Time) registry.Class
+}
+
+// ValidationStore defines the interface for keeping the validations attached to a session.
+type ValidationStore interface {
+	AppendToList(listKey string, item string) error
+	GetListItems(listKey string) ([]string, error)
+	DeleteKey(key string) error
+}
+
+// AlertedStore defines the interface for recording the sessions an alert was raised for.
+type AlertedStore interface {
+	AddFieldsToHash(hashKey string, fields map[string]string) error
+	GetAllFields(hashKey string) (map[string]string, error)
+}
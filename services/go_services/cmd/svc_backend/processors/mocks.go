@@ -1,27 +1,55 @@
 package processors
 
 import (
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/sessions"
 	"time"
 )
 
-// MockDataStore is a mock implementation of the DataStore interface.
-type MockDataStore struct {
-	AddFieldToHashFunc func(hashKey string, fieldName string, fieldValue time.Time) error
-	GetFieldAsTimeFunc func(hashKey, fieldName, layout string) (time.Time, error)
+// MockSessionStore is a mock implementation of the SessionStore interface.
+type MockSessionStore struct {
+	OpenSessionFunc    func(session models.Session) error
+	CloseSessionFunc   func(vehiclePlate string, exitDateTime time.Time) (models.Session, error)
+	GetOpenSessionFunc func(vehiclePlate string) (models.Session, bool, error)
+	ListOpenFunc       func() ([]models.Session, error)
+
+	GetClosedSessionFunc func(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error)
 }
 
-func (m *MockDataStore) AddFieldToHash(hashKey string, fieldName string, fieldValue time.Time) error {
-	if m.AddFieldToHashFunc != nil {
-		return m.AddFieldToHashFunc(hashKey, fieldName, fieldValue)
+func (m *MockSessionStore) OpenSession(session models.Session) error {
+	if m.OpenSessionFunc != nil {
+		return m.OpenSessionFunc(session)
 	}
 	return nil
 }
 
-func (m *MockDataStore) GetFieldAsTime(hashKey string, fieldName string, layout string) (time.Time, error) {
-	if m.GetFieldAsTimeFunc != nil {
-		return m.GetFieldAsTimeFunc(hashKey, fieldName, layout)
+func (m *MockSessionStore) CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+	if m.CloseSessionFunc != nil {
+		return m.CloseSessionFunc(vehiclePlate, exitDateTime)
+	}
+	return models.Session{}, sessions.ErrNoOpenSession
+}
+
+func (m *MockSessionStore) GetOpenSession(vehiclePlate string) (models.Session, bool, error) {
+	if m.GetOpenSessionFunc != nil {
+		return m.GetOpenSessionFunc(vehiclePlate)
 	}
-	return time.Time{}, nil
+	return models.Session{}, false, nil
+}
+
+func (m *MockSessionStore) GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error) {
+	if m.GetClosedSessionFunc != nil {
+		return m.GetClosedSessionFunc(vehiclePlate, exitDateTime)
+	}
+	return models.Session{}, false, nil
+}
+
+func (m *MockSessionStore) ListOpen() ([]models.Session, error) {
+	if m.ListOpenFunc != nil {
+		return m.ListOpenFunc()
+	}
+	return nil, nil
 }
 
 // MockSummaryPoster is a mock implementation of the SummaryPoster interface for testing.
@@ -35,3 +63,69 @@ func (m *MockSummaryPoster) PostSummary(data interface{}) error {
 	}
 	return nil
 }
+
+// MockOrphanRecorder is a mock implementation of the OrphanRecorder interface for testing.
+type MockOrphanRecorder struct {
+	RecordOrphanExitFunc func(event models.ExitEvent) error
+}
+
+func (m *MockOrphanRecorder) RecordOrphanExit(event models.ExitEvent) error {
+	if m.RecordOrphanExitFunc != nil {
+		return m.RecordOrphanExitFunc(event)
+	}
+	return nil
+}
+
+// MockAlertPublisher is a mock implementation of the AlertPublisher interface that keeps published alerts.
+type MockAlertPublisher struct {
+	Alerts []models.Alert
+}
+
+func (m *MockAlertPublisher) PublishAlert(alert models.Alert) error {
+	m.Alerts = append(m.Alerts, alert)
+	return nil
+}
+
+// MockReviewPublisher is a mock implementation of the ReviewPublisher interface that keeps published items.
+type MockReviewPublisher struct {
+	Items []models.ReviewItem
+}
+
+func (m *MockReviewPublisher) PublishReview(item models.ReviewItem) error {
+	m.Items = append(m.Items, item)
+	return nil
+}
+
+// MockPlateClassifier is a mock implementation of the PlateClassifier interface; unknown plates are visitors.
+type MockPlateClassifier struct {
+	Classes map[string]registry.Class
+}
+
+func (m *MockPlateClassifier) Classify(vehiclePlate string, at time.Time) registry.Class {
+	if class, ok := m.Classes[vehiclePlate]; ok {
+		return class
+	}
+	return registry.Visitor
+}
+
+// MockValidationStore is an in-memory implementation of the ValidationStore interface for testing.
+type MockValidationStore struct {
+	Lists map[string][]string
+}
+
+func (m *MockValidationStore) AppendToList(listKey string, item string) error {
+	if m.Lists == nil {
+		m.Lists = make(map[string][]string)
+	}
+	m.Lists[listKey] = append(m.Lists[listKey], item)
+	return nil
+}
+
+func (m *MockValidationStore) GetListItems(listKey string) ([]string, error) {
+	return m.Lists[listKey], nil
+}
+
+func (m *MockValidationStore) DeleteKey(key string) error {
+	delete(m.Lists, key)
+	return nil
+}
@@ -0,0 +1,17 @@
+package processors
+
+import "errors"
+
+// MultiPoster fans a summary out to several SummaryPoster implementations.
+type MultiPoster []SummaryPoster
+
+// PostSummary posts the data to every poster, returning the combined errors of those that failed.
+func (m MultiPoster) PostSummary(data interface{}) error {
+	var errs []error
+	for _, poster := range m {
+		if err := poster.PostSummary(data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,158 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/metrics"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/logger"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// overstayAlertedKey is the hash of the entry time each plate was last alerted for.
+const overstayAlertedKey = "overstay_alerted"
+
+// OverstayScanner periodically looks for sessions that have been open longer than Threshold,
+// raising an alert for each and optionally closing them out as suspected missed exits.
+type OverstayScanner struct {
+	Sessions       SessionStore
+	AlertPublisher AlertPublisher
+	SummaryPoster  SummaryPoster // optional; receives the missed-exit summary of closed out sessions
+	Alerted        AlertedStore  // optional; sessions recorded in it are alerted once rather than on every scan
+	Threshold      time.Duration
+	CloseOut       bool
+	Now            func() time.Time // defaults to time.Now
+}
+
+// Run scans every interval until stop is closed.
+func (s *OverstayScanner) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := s.Scan(); err != nil {
+				logger.Log.Error().Err(err).Msg("Overstay scan failed")
+			}
+		}
+	}
+}
+
+// Scan checks all stored sessions once and returns the number of overstayed sessions found.
+func (s *OverstayScanner) Scan() (int, error) {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	openSessions, err := s.Sessions.ListOpen()
+	if err != nil {
+		return 0, err
+	}
+
+	alerted, err := s.alertedSessions()
+	if err != nil {
+		return 0, err
+	}
+
+	overstayed := 0
+	for _, session := range openSessions {
+		if now.Sub(session.EntryDateTime) < s.Threshold {
+			continue
+		}
+		overstayed++
+		if alerted[session.VehiclePlate] == alertedValue(session.EntryDateTime) {
+			continue
+		}
+
+		if err := s.handleOverstay(session.VehiclePlate, session.EntryDateTime, now); err != nil {
+			logger.Log.Error().Err(err).Msgf("Failed handling overstay for %s", session.VehiclePlate)
+		}
+	}
+
+	logger.Log.Debug().Msgf("Overstay scan found %d of %d open sessions over %v", overstayed, len(openSessions), s.Threshold)
+	// metrics instrumentation:
+	metrics.OverstaySessions.Set(float64(overstayed))
+
+	return overstayed, nil
+}
+
+func (s *OverstayScanner) handleOverstay(vehiclePlate string, entryDateTime time.Time, now time.Time) error {
+	alert := models.Alert{
+		Type:          models.AlertTypeOverstay,
+		VehiclePlate:  vehiclePlate,
+		EntryDateTime: entryDateTime,
+		DetectedAt:    now,
+		Detail:        fmt.Sprintf("session open for %v, threshold %v", now.Sub(entryDateTime).Round(time.Second), s.Threshold),
+	}
+	if err := s.AlertPublisher.PublishAlert(alert); err != nil {
+		return fmt.Errorf("error publishing overstay alert: %w", err)
+	}
+	// metrics instrumentation:
+	metrics.OverstayAlerts.With(prometheus.Labels{"closed_out": strconv.FormatBool(s.CloseOut)}).Inc()
+
+	if !s.CloseOut {
+		return s.recordAlerted(vehiclePlate, entryDateTime)
+	}
+	return closeAsMissedExit(s.Sessions, s.SummaryPoster, vehiclePlate, now)
+}
+
+// alertedSessions returns the entry time each plate was last alerted for, keyed by plate.
+func (s *OverstayScanner) alertedSessions() (map[string]string, error) {
+	if s.Alerted == nil {
+		return nil, nil
+	}
+	alerted, err := s.Alerted.GetAllFields(overstayAlertedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alerted overstays: %w", err)
+	}
+	return alerted, nil
+}
+
+// recordAlerted records the alerted session by plate and entry time; a later session of the plate
+// replaces it and is alerted again.
+func (s *OverstayScanner) recordAlerted(vehiclePlate string, entryDateTime time.Time) error {
+	if s.Alerted == nil {
+		return nil
+	}
+	if err := s.Alerted.AddFieldsToHash(overstayAlertedKey, map[string]string{vehiclePlate: alertedValue(entryDateTime)}); err != nil {
+		return fmt.Errorf("error recording overstay alert: %w", err)
+	}
+	return nil
+}
+
+func alertedValue(entryDateTime time.Time) string {
+	return entryDateTime.UTC().Format(time.RFC3339Nano)
+}
+
+// closeAsMissedExit ends an open session at closedAt and hands its summary, marked as a missed exit,
+// to the poster when one is configured. A session closed by a real exit in the meantime is left alone.
+func closeAsMissedExit(sessionStore SessionStore, poster SummaryPoster, vehiclePlate string, closedAt time.Time) error {
+	session, err := sessionStore.CloseSession(vehiclePlate, closedAt)
+	if errors.Is(err, sessions.ErrNoOpenSession) {
+		logger.Log.Debug().Msgf("Session of %s already closed", vehiclePlate)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error closing session: %w", err)
+	}
+	logger.Log.Info().Msgf("Closed session of %s as missed exit", vehiclePlate)
+
+	if poster == nil {
+		return nil
+	}
+	parkingLog, err := GenerateParkingSummary(vehiclePlate, closedAt, session.EntryDateTime)
+	if err != nil {
+		return err
+	}
+	parkingLog.Status = models.StatusMissedExit
+	parkingLog.RawEntryPlate = session.RawEntryPlate
+	return poster.PostSummary(*parkingLog)
+}
@@ -0,0 +1,119 @@
+package processors
+
+import (
+	"go_services/cmd/svc_backend/metrics"
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/memstore"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverstayScanner_Scan(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2024-09-12T12:00:00Z")
+	longAgo, _ := time.Parse(time.RFC3339, "2024-09-10T08:00:00Z")
+	recently, _ := time.Parse(time.RFC3339, "2024-09-12T10:00:00Z")
+	openSessions := []models.Session{
+		{VehiclePlate: "OVERSTAY1", EntryDateTime: longAgo},
+		{VehiclePlate: "RECENT1", EntryDateTime: recently},
+		{VehiclePlate: "REENTER1", EntryDateTime: longAgo},
+	}
+
+	testCases := []struct {
+		name           string
+		closeOut       bool
+		expectedClosed []string
+	}{
+		{name: "AlertOnly", closeOut: false},
+		{name: "CloseOut", closeOut: true, expectedClosed: []string{"OVERSTAY1", "REENTER1"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			metrics.OverstaySessions.Set(0)
+
+			var closed []string
+			var posted []models.ParkingLog
+			alerts := &MockAlertPublisher{}
+			scanner := &OverstayScanner{
+				Sessions: &MockSessionStore{
+					ListOpenFunc: func() ([]models.Session, error) {
+						return openSessions, nil
+					},
+					CloseSessionFunc: func(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+						assert.Equal(t, now, exitDateTime)
+						closed = append(closed, vehiclePlate)
+						return models.Session{VehiclePlate: vehiclePlate, EntryDateTime: longAgo, ExitDateTime: exitDateTime}, nil
+					},
+				},
+				AlertPublisher: alerts,
+				SummaryPoster: &MockSummaryPoster{
+					PostSummaryFunc: func(data interface{}) error {
+						posted = append(posted, data.(models.ParkingLog))
+						return nil
+					},
+				},
+				Threshold: 24 * time.Hour,
+				CloseOut:  testCase.closeOut,
+				Now:       func() time.Time { return now },
+			}
+
+			overstayed, err := scanner.Scan()
+
+			assert.NoError(t, err)
+			assert.Equal(t, 2, overstayed)
+			assert.Equal(t, 2.0, testutil.ToFloat64(metrics.OverstaySessions))
+
+			alertedPlates := []string{}
+			for _, alert := range alerts.Alerts {
+				assert.Equal(t, models.AlertTypeOverstay, alert.Type)
+				assert.Equal(t, now, alert.DetectedAt)
+				alertedPlates = append(alertedPlates, alert.VehiclePlate)
+			}
+			assert.ElementsMatch(t, []string{"OVERSTAY1", "REENTER1"}, alertedPlates)
+
+			assert.ElementsMatch(t, testCase.expectedClosed, closed)
+			assert.Len(t, posted, len(testCase.expectedClosed))
+			for _, parkingLog := range posted {
+				assert.Equal(t, models.StatusMissedExit, parkingLog.Status)
+				assert.Equal(t, now, parkingLog.ExitDateTime)
+			}
+		})
+	}
+}
+
+func TestOverstayScanner_AlertsOncePerSession(t *testing.T) {
+	now, _ := time.Parse(time.RFC3339, "2024-09-12T12:00:00Z")
+	longAgo, _ := time.Parse(time.RFC3339, "2024-09-10T08:00:00Z")
+	reentry, _ := time.Parse(time.RFC3339, "2024-09-11T08:00:00Z")
+	openSessions := []models.Session{{VehiclePlate: "OVERSTAY1", EntryDateTime: longAgo}}
+
+	alerts := &MockAlertPublisher{}
+	scanner := &OverstayScanner{
+		Sessions: &MockSessionStore{
+			ListOpenFunc: func() ([]models.Session, error) { return openSessions, nil },
+		},
+		AlertPublisher: alerts,
+		Alerted:        memstore.New(),
+		Threshold:      24 * time.Hour,
+		Now:            func() time.Time { return now },
+	}
+	alertsBefore := testutil.ToFloat64(metrics.OverstayAlerts.WithLabelValues("false"))
+
+	for i := 0; i < 3; i++ {
+		overstayed, err := scanner.Scan()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, overstayed)
+	}
+	assert.Len(t, alerts.Alerts, 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.OverstayAlerts.WithLabelValues("false"))-alertsBefore)
+
+	// a later session of the plate is alerted again
+	openSessions = []models.Session{{VehiclePlate: "OVERSTAY1", EntryDateTime: reentry}}
+	_, err := scanner.Scan()
+	assert.NoError(t, err)
+	assert.Len(t, alerts.Alerts, 2)
+	assert.Equal(t, reentry, alerts.Alerts[1].EntryDateTime)
+}
@@ -0,0 +1,81 @@
+package processors
+
+import (
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/matching"
+	"go_services/cmd/svc_backend/metrics"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/logger"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errSentForReview signals that an exit was routed to manual review instead of being billed.
+var errSentForReview = errors.New("exit sent for review")
+
+// matchOpenSession looks for an open session whose plate is a near match of the exit plate.
+// A confident match is closed with the exit time and returned; a weaker or ambiguous one is published
+// for review and errSentForReview is returned. Without a candidate the returned error wraps
+// sessions.ErrNoOpenSession so that the exit is handled as an orphan.
+func (p *ExitEventProcessor) matchOpenSession(payload models.ExitEvent) (*matching.Match, models.Session, error) {
+	openSessions, err := p.Sessions.ListOpen()
+	if err != nil {
+		return nil, models.Session{}, err
+	}
+
+	entries := make(map[string]time.Time, len(openSessions))
+	candidates := make([]string, 0, len(openSessions))
+	for _, session := range openSessions {
+		if session.VehiclePlate == payload.VehiclePlate {
+			continue
+		}
+		entries[session.VehiclePlate] = session.EntryDateTime
+		candidates = append(candidates, session.VehiclePlate)
+	}
+
+	match, found := p.PlateMatcher.BestMatch(payload.VehiclePlate, candidates)
+	if !found {
+		// metrics instrumentation:
+		metrics.PlateMatches.With(prometheus.Labels{"outcome": "none"}).Inc()
+		return nil, models.Session{}, fmt.Errorf("%w: no entry or near match for %s", sessions.ErrNoOpenSession, payload.VehiclePlate)
+	}
+
+	if !p.PlateMatcher.Accepts(match) {
+		reason := models.ReviewReasonLowConfidenceMatch
+		if match.Ambiguous {
+			reason = models.ReviewReasonAmbiguousMatch
+			logger.Log.Info().Msgf("Exit plate %s near matches both %s and %s, sending for review", payload.VehiclePlate, match.Plate, match.RunnerUp)
+		} else {
+			logger.Log.Info().Msgf("Exit plate %s near matches %s with confidence %.2f, sending for review", payload.VehiclePlate, match.Plate, match.Confidence)
+		}
+		// metrics instrumentation:
+		metrics.PlateMatches.With(prometheus.Labels{"outcome": "review"}).Inc()
+		if p.ReviewPublisher == nil {
+			return nil, models.Session{}, fmt.Errorf("%w: no review publisher for near match of %s", sessions.ErrNoOpenSession, payload.VehiclePlate)
+		}
+		candidateEntry := entries[match.Plate]
+		item := models.ReviewItem{
+			Reason:         reason,
+			ExitEvent:      &payload,
+			CandidatePlate: match.Plate,
+			CandidateEntry: &candidateEntry,
+			Confidence:     match.Confidence,
+		}
+		if err := p.ReviewPublisher.PublishReview(item); err != nil {
+			return nil, models.Session{}, fmt.Errorf("error publishing review item: %w", err)
+		}
+		return nil, models.Session{}, errSentForReview
+	}
+
+	logger.Log.Info().Msgf("Exit plate %s matched to %s with confidence %.2f", payload.VehiclePlate, match.Plate, match.Confidence)
+	// metrics instrumentation:
+	metrics.PlateMatches.With(prometheus.Labels{"outcome": "accepted"}).Inc()
+	session, err := p.Sessions.CloseSession(match.Plate, payload.ExitDateTime)
+	if err != nil {
+		return nil, models.Session{}, err
+	}
+	return &match, session, nil
+}
@@ -0,0 +1,62 @@
+package processors
+
+import (
+	"encoding/json"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/memstore"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProcessors_InMemoryStorage runs entries, validations and exits against the in-memory store
+// with the same hash layout as Redis.
+func TestProcessors_InMemoryStorage(t *testing.T) {
+	store := memstore.New()
+	sessionStore := &sessions.RedisStore{Client: store}
+	var posted []models.ParkingLog
+	poster := &MockSummaryPoster{
+		PostSummaryFunc: func(data interface{}) error {
+			posted = append(posted, data.(models.ParkingLog))
+			return nil
+		},
+	}
+	var orphans []models.ExitEvent
+
+	entryProcessor := &EntryEventProcessor{Sessions: sessionStore}
+	validationProcessor := &ValidationEventProcessor{Sessions: sessionStore, Validations: store}
+	exitProcessor := &ExitEventProcessor{
+		Sessions:      sessionStore,
+		SummaryPoster: poster,
+		Tariff:        Tariff{HourlyRateCents: 200},
+		Validations:   store,
+		OrphanRecorder: &MockOrphanRecorder{
+			RecordOrphanExitFunc: func(event models.ExitEvent) error {
+				orphans = append(orphans, event)
+				return nil
+			},
+		},
+	}
+
+	entry, _ := time.Parse(time.RFC3339, "2024-09-11T10:00:00Z")
+	process := func(processor interface{ ProcessMessage([]byte) error }, event interface{}) error {
+		body, _ := json.Marshal(event)
+		return processor.ProcessMessage(body)
+	}
+
+	assert.NoError(t, process(entryProcessor, models.EntryEvent{VehiclePlate: "abc 123", EntryDateTime: entry}))
+	assert.NoError(t, process(validationProcessor, models.ValidationEvent{ID: "v1", VehiclePlate: "ABC123", Kind: models.ValidationFreeMinutes, Value: 60, IssuedAt: entry.Add(time.Hour)}))
+	assert.NoError(t, process(exitProcessor, models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: entry.Add(150 * time.Minute)}))
+	assert.Error(t, process(exitProcessor, models.ExitEvent{VehiclePlate: "ABC123", ExitDateTime: entry.Add(160 * time.Minute)}))
+
+	assert.Len(t, posted, 1)
+	assert.Equal(t, "2h30m0s", posted[0].Duration)
+	assert.Equal(t, "abc 123", posted[0].RawEntryPlate)
+	assert.Equal(t, "ABC123", posted[0].RawVehiclePlate)
+	assert.Equal(t, int64(400), posted[0].FeeCents) // 3 started hours, one validated
+	assert.Len(t, orphans, 1, "a second exit for the closed session is an orphan")
+	open, _ := sessionStore.ListOpen()
+	assert.Empty(t, open)
+}
@@ -0,0 +1,72 @@
+package processors
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"math"
+	"time"
+)
+
+// Tariff describes how a parking stay is priced.
+type Tariff struct {
+	HourlyRateCents int64
+}
+
+// Fee returns the fee in cents for a stay of the given duration, charging for every started hour.
+func (t Tariff) Fee(stay time.Duration) int64 {
+	if stay <= 0 || t.HourlyRateCents <= 0 {
+		return 0
+	}
+
+	startedHours := int64(stay / time.Hour)
+	if stay%time.Hour != 0 {
+		startedHours++
+	}
+
+	return startedHours * t.HourlyRateCents
+}
+
+// FeeWithValidations returns the fee for a stay after applying the validations, together with the
+// itemised discounts. Free time is deducted from the stay first, percentage discounts are then
+// applied to the remaining fee in the order given.
+func (t Tariff) FeeWithValidations(stay time.Duration, validations []models.ValidationEvent) (int64, []models.AppliedDiscount) {
+	var discounts []models.AppliedDiscount
+
+	billable := stay
+	for _, validation := range validations {
+		if validation.Kind != models.ValidationFreeMinutes || validation.Value <= 0 {
+			continue
+		}
+		before := t.Fee(billable)
+		billable -= time.Duration(validation.Value * float64(time.Minute))
+		discounts = append(discounts, models.AppliedDiscount{
+			ValidationID: validation.ID,
+			Description:  describeValidation(fmt.Sprintf("%g free minutes", validation.Value), validation.Issuer),
+			AmountCents:  before - t.Fee(billable),
+		})
+	}
+
+	fee := t.Fee(billable)
+	for _, validation := range validations {
+		if validation.Kind != models.ValidationPercentOff || validation.Value <= 0 {
+			continue
+		}
+		percent := math.Min(validation.Value, 100)
+		amount := int64(math.Round(float64(fee) * percent / 100))
+		fee -= amount
+		discounts = append(discounts, models.AppliedDiscount{
+			ValidationID: validation.ID,
+			Description:  describeValidation(fmt.Sprintf("%g%% off", percent), validation.Issuer),
+			AmountCents:  amount,
+		})
+	}
+
+	return fee, discounts
+}
+
+func describeValidation(discount string, issuer string) string {
+	if issuer == "" {
+		return discount
+	}
+	return discount + " validated by " + issuer
+}
@@ -0,0 +1,99 @@
+package processors
+
+import (
+	"go_services/cmd/svc_backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTariff_Fee(t *testing.T) {
+	tariff := Tariff{HourlyRateCents: 200}
+
+	assert.Equal(t, int64(0), tariff.Fee(0))
+	assert.Equal(t, int64(200), tariff.Fee(time.Minute))
+	assert.Equal(t, int64(200), tariff.Fee(time.Hour))
+	assert.Equal(t, int64(400), tariff.Fee(time.Hour+time.Second))
+	assert.Equal(t, int64(0), Tariff{}.Fee(5*time.Hour))
+}
+
+func TestTariff_FeeWithValidations(t *testing.T) {
+	tariff := Tariff{HourlyRateCents: 200}
+	stay := 3*time.Hour + 30*time.Minute // 4 started hours, 800 cents
+
+	tests := []struct {
+		name              string
+		validations       []models.ValidationEvent
+		expectedFee       int64
+		expectedDiscounts []models.AppliedDiscount
+	}{
+		{
+			name:        "No Validations",
+			expectedFee: 800,
+		},
+		{
+			name: "First Two Hours Free",
+			validations: []models.ValidationEvent{
+				{ID: "v1", Kind: models.ValidationFreeMinutes, Value: 120, Issuer: "Bookshop"},
+			},
+			expectedFee: 400,
+			expectedDiscounts: []models.AppliedDiscount{
+				{ValidationID: "v1", Description: "120 free minutes validated by Bookshop", AmountCents: 400},
+			},
+		},
+		{
+			name: "Half Off",
+			validations: []models.ValidationEvent{
+				{ID: "v1", Kind: models.ValidationPercentOff, Value: 50},
+			},
+			expectedFee: 400,
+			expectedDiscounts: []models.AppliedDiscount{
+				{ValidationID: "v1", Description: "50% off", AmountCents: 400},
+			},
+		},
+		{
+			name: "Free Time Applied Before Percentage",
+			validations: []models.ValidationEvent{
+				{ID: "v1", Kind: models.ValidationPercentOff, Value: 25, Issuer: "Cinema"},
+				{ID: "v2", Kind: models.ValidationFreeMinutes, Value: 60, Issuer: "Bookshop"},
+			},
+			expectedFee: 450,
+			expectedDiscounts: []models.AppliedDiscount{
+				{ValidationID: "v2", Description: "60 free minutes validated by Bookshop", AmountCents: 200},
+				{ValidationID: "v1", Description: "25% off validated by Cinema", AmountCents: 150},
+			},
+		},
+		{
+			name: "Free Time Exceeding Stay",
+			validations: []models.ValidationEvent{
+				{ID: "v1", Kind: models.ValidationFreeMinutes, Value: 600},
+				{ID: "v2", Kind: models.ValidationPercentOff, Value: 50},
+			},
+			expectedFee: 0,
+			expectedDiscounts: []models.AppliedDiscount{
+				{ValidationID: "v1", Description: "600 free minutes", AmountCents: 800},
+				{ValidationID: "v2", Description: "50% off", AmountCents: 0},
+			},
+		},
+		{
+			name: "Percentage Capped At Full Fee",
+			validations: []models.ValidationEvent{
+				{ID: "v1", Kind: models.ValidationPercentOff, Value: 150},
+			},
+			expectedFee: 0,
+			expectedDiscounts: []models.AppliedDiscount{
+				{ValidationID: "v1", Description: "100% off", AmountCents: 800},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, discounts := tariff.FeeWithValidations(stay, tt.validations)
+
+			assert.Equal(t, tt.expectedFee, fee)
+			assert.Equal(t, tt.expectedDiscounts, discounts)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package processors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/metrics"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/plates"
+	"go_services/pkg/logger"
+	"go_services/pkg/redis"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errNoOpenSession is returned for validations of vehicles that are not parked.
+var errNoOpenSession = errors.New("no open session")
+
+// ValidationEventProcessor handles validation events by attaching them to the open session of the vehicle.
+type ValidationEventProcessor struct {
+	Sessions    SessionStore
+	Validations ValidationStore
+	Normalizer  *plates.Normalizer
+}
+
+// ProcessMessage processes a validation event message.
+func (p *ValidationEventProcessor) ProcessMessage(msgBody []byte) error {
+	start := time.Now() // metrics instrumentation: start timer
+	logger.Log.Info().Msg("Process Validation Event")
+	var payload models.ValidationEvent
+	if err := json.Unmarshal(msgBody, &payload); err != nil {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "json_unmarshal"}).Inc()
+		return err
+	}
+	if err := validateValidation(payload); err != nil {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "invalid_validation"}).Inc()
+		return err
+	}
+	payload.VehiclePlate = p.Normalizer.Normalize(payload.VehiclePlate)
+	if payload.IssuedAt.IsZero() {
+		payload.IssuedAt = time.Now().UTC()
+	}
+
+	// Validations can only be attached to a vehicle that is parked
+	session, open, err := p.Sessions.GetOpenSession(payload.VehiclePlate)
+	if err != nil {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "db_read_error"}).Inc()
+		return err
+	}
+	if !open || payload.IssuedAt.Before(session.EntryDateTime) {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "no_open_session"}).Inc()
+		return fmt.Errorf("%w: %s is not parked at %v", errNoOpenSession, payload.VehiclePlate, payload.IssuedAt)
+	}
+
+	// A redelivered validation is attached once
+	attached, err := p.attached(payload)
+	if err != nil {
+		// metrics instrumentation:
+		metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "db_read_error"}).Inc()
+		return err
+	}
+	if attached {
+		logger.Log.Info().Msgf("Validation %s of %s is already attached", payload.ID, payload.VehiclePlate)
+	} else {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error marshaling JSON: %v", err)
+		}
+		if err := p.Validations.AppendToList(validationsKey(payload.VehiclePlate), string(body)); err != nil {
+			// metrics instrumentation:
+			metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": "db_write_error"}).Inc()
+			return err
+		}
+	}
+
+	logger.Log.Info().Msg("Process Validation Event Success")
+	// metrics instrumentation: Record the duration taken to process the message
+	duration := time.Since(start).Seconds()
+	metrics.EventProcessingLatency.With(prometheus.Labels{"event_type": "validation"}).Observe(duration)
+
+	// metrics instrumentation:
+	metrics.EventProcessingSuccesses.With(prometheus.Labels{"event_type": "validation"}).Inc()
+
+	return nil
+}
+
+// attached reports whether a validation with the ID of the validation is attached to the plate.
+// Validations without an ID cannot be told apart and are always attached.
+func (p *ValidationEventProcessor) attached(validation models.ValidationEvent) (bool, error) {
+	if validation.ID == "" {
+		return false, nil
+	}
+	items, err := p.Validations.GetListItems(validationsKey(validation.VehiclePlate))
+	if err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		var existing models.ValidationEvent
+		if err := json.Unmarshal([]byte(item), &existing); err == nil && existing.ID == validation.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validationsKey returns the key of the list holding the validations of a plate; it is hash-tagged with
+// the plate like the session hash, so both land in the same Redis Cluster slot.
+func validationsKey(vehiclePlate string) string {
+	return "validations:" + redis.HashTag(vehiclePlate)
+}
+
+func validateValidation(validation models.ValidationEvent) error {
+	switch validation.Kind {
+	case models.ValidationFreeMinutes, models.ValidationPercentOff:
+	default:
+		return fmt.Errorf("unknown validation kind %q", validation.Kind)
+	}
+	if validation.Value <= 0 {
+		return fmt.Errorf("validation value must be positive, got %v", validation.Value)
+	}
+	return nil
+}
+
+// sessionValidations returns the validations issued during the session, each ID once, and whether the
+// plate has stored validations at all. They are kept in the store until clearValidations removes them
+// once the summary was posted.
+func sessionValidations(store ValidationStore, vehiclePlate string, entryDateTime time.Time, exitDateTime time.Time) ([]models.ValidationEvent, bool, error) {
+	items, err := store.GetListItems(validationsKey(vehiclePlate))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var validations []models.ValidationEvent
+	seen := make(map[string]bool)
+	for _, item := range items {
+		var validation models.ValidationEvent
+		if err := json.Unmarshal([]byte(item), &validation); err != nil {
+			logger.Log.Error().Err(err).Msgf("Skipping undecodable validation of %s", vehiclePlate)
+			continue
+		}
+		if validation.IssuedAt.Before(entryDateTime) || validation.IssuedAt.After(exitDateTime) {
+			continue
+		}
+		if validation.ID != "" {
+			if seen[validation.ID] {
+				continue
+			}
+			seen[validation.ID] = true
+		}
+		validations = append(validations, validation)
+	}
+	return validations, len(items) > 0, nil
+}
+
+// clearValidations removes the validations of the plate once they were billed.
+func clearValidations(store ValidationStore, vehiclePlate string) {
+	if err := store.DeleteKey(validationsKey(vehiclePlate)); err != nil {
+		logger.Log.Error().Err(err).Msgf("Failed removing used validations of %s", vehiclePlate)
+	}
+}
@@ -0,0 +1,104 @@
+package processors
+
+import (
+	"encoding/json"
+	"go_services/cmd/svc_backend/metrics"
+	"go_services/cmd/svc_backend/models"
+	"go_services/cmd/svc_backend/sessions"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationEventProcessor_ProcessMessage(t *testing.T) {
+	entryDateTime, _ := time.Parse(time.RFC3339, "2024-09-11T10:00:00Z")
+	issuedAt := entryDateTime.Add(30 * time.Minute)
+
+	tests := []struct {
+		name          string
+		validation    models.ValidationEvent
+		storedEntry   time.Time
+		storedExit    time.Time
+		attached      bool // the validation was attached before
+		expectedError bool
+		errorStage    string
+	}{
+		{
+			name:        "Attached To Open Session",
+			validation:  models.ValidationEvent{ID: "v1", VehiclePlate: "abc 123", Kind: models.ValidationFreeMinutes, Value: 120, IssuedAt: issuedAt},
+			storedEntry: entryDateTime,
+		},
+		{
+			name:        "Redelivered Validation Attached Once",
+			validation:  models.ValidationEvent{ID: "v1", VehiclePlate: "abc 123", Kind: models.ValidationFreeMinutes, Value: 120, IssuedAt: issuedAt},
+			storedEntry: entryDateTime,
+			attached:    true,
+		},
+		{
+			name:          "Unknown Kind",
+			validation:    models.ValidationEvent{ID: "v1", VehiclePlate: "ABC123", Kind: "buy_one_get_one", Value: 1, IssuedAt: issuedAt},
+			storedEntry:   entryDateTime,
+			expectedError: true,
+			errorStage:    "invalid_validation",
+		},
+		{
+			name:          "Vehicle Not Parked",
+			validation:    models.ValidationEvent{ID: "v1", VehiclePlate: "ABC123", Kind: models.ValidationPercentOff, Value: 50, IssuedAt: issuedAt},
+			expectedError: true,
+			errorStage:    "no_open_session",
+		},
+		{
+			name:          "Vehicle Already Exited",
+			validation:    models.ValidationEvent{ID: "v1", VehiclePlate: "ABC123", Kind: models.ValidationPercentOff, Value: 50, IssuedAt: issuedAt},
+			storedEntry:   entryDateTime,
+			storedExit:    entryDateTime.Add(10 * time.Minute),
+			expectedError: true,
+			errorStage:    "no_open_session",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics.EventProcessingFails.Reset()
+			metrics.EventProcessingSuccesses.Reset()
+
+			sessionStore := sessions.NewMemoryStore()
+			if !tt.storedEntry.IsZero() {
+				assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: tt.storedEntry}))
+			}
+			if !tt.storedExit.IsZero() {
+				_, err := sessionStore.CloseSession("ABC123", tt.storedExit)
+				assert.NoError(t, err)
+			}
+			store := &MockValidationStore{}
+			if tt.attached {
+				body, _ := json.Marshal(models.ValidationEvent{ID: tt.validation.ID, VehiclePlate: "ABC123", Kind: tt.validation.Kind, Value: tt.validation.Value, IssuedAt: issuedAt})
+				assert.NoError(t, store.AppendToList("validations:{ABC123}", string(body)))
+			}
+			processor := &ValidationEventProcessor{
+				Sessions:    sessionStore,
+				Validations: store,
+			}
+
+			msgBody, _ := json.Marshal(tt.validation)
+			err := processor.ProcessMessage(msgBody)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				assert.Empty(t, store.Lists)
+				count := testutil.ToFloat64(metrics.EventProcessingFails.With(prometheus.Labels{"event_type": "validation", "error_stage": tt.errorStage}))
+				assert.Equal(t, 1.0, count)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, store.Lists["validations:{ABC123}"], 1)
+			var stored models.ValidationEvent
+			assert.NoError(t, json.Unmarshal([]byte(store.Lists["validations:{ABC123}"][0]), &stored))
+			assert.Equal(t, "ABC123", stored.VehiclePlate)
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.EventProcessingSuccesses.With(prometheus.Labels{"event_type": "validation"})))
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Class is the billing classification of a plate.
+type Class string
+
+const (
+	Visitor   Class = "visitor"
+	Permit    Class = "permit"
+	Whitelist Class = "whitelist"
+	Blacklist Class = "blacklist"
+)
+
+// precedence decides which class wins when several entries of a plate are valid at the same time.
+var precedence = map[Class]int{
+	Visitor:   0,
+	Whitelist: 1,
+	Permit:    2,
+	Blacklist: 3,
+}
+
+// Entry registers a plate with a class for an optional validity period.
+// A zero ValidFrom or ValidUntil leaves that end of the period open.
+type Entry struct {
+	Plate      string    `json:"plate"`
+	Class      Class     `json:"class"`
+	ValidFrom  time.Time `json:"valid_from,omitempty"`
+	ValidUntil time.Time `json:"valid_until,omitempty"`
+	Note       string    `json:"note,omitempty"`
+}
+
+// ValidAt reports whether the entry applies at the given time.
+func (e Entry) ValidAt(at time.Time) bool {
+	if !e.ValidFrom.IsZero() && at.Before(e.ValidFrom) {
+		return false
+	}
+	if !e.ValidUntil.IsZero() && at.After(e.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// HashReader defines the interface for reading all fields of a Redis hash.
+type HashReader interface {
+	GetAllFields(hashKey string) (map[string]string, error)
+}
+
+// Registry looks up the classification of plates.
+type Registry struct {
+	entries map[string][]Entry
+}
+
+// NewRegistry indexes the entries by plate. normalize, when set, is applied to the registered plates
+// so that they are looked up in the same form as the processors use.
+func NewRegistry(entries []Entry, normalize func(string) string) (*Registry, error) {
+	r := &Registry{entries: make(map[string][]Entry)}
+	for _, entry := range entries {
+		if _, ok := precedence[entry.Class]; !ok {
+			return nil, fmt.Errorf("unknown class %q for plate %s", entry.Class, entry.Plate)
+		}
+		if normalize != nil {
+			entry.Plate = normalize(entry.Plate)
+		}
+		r.entries[entry.Plate] = append(r.entries[entry.Plate], entry)
+	}
+	return r, nil
+}
+
+// Classify returns the class of the plate at the given time; unregistered plates are visitors.
+func (r *Registry) Classify(vehiclePlate string, at time.Time) Class {
+	class := Visitor
+	if r == nil {
+		return class
+	}
+	for _, entry := range r.entries[vehiclePlate] {
+		if entry.ValidAt(at) && precedence[entry.Class] > precedence[class] {
+			class = entry.Class
+		}
+	}
+	return class
+}
+
+// Len returns the number of registered entries.
+func (r *Registry) Len() int {
+	n := 0
+	for _, entries := range r.entries {
+		n += len(entries)
+	}
+	return n
+}
+
+// LoadFile reads a JSON array of entries from a file.
+func LoadFile(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plate registry: %v", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding plate registry: %v", err)
+	}
+	return entries, nil
+}
+
+// LoadHash reads entries from a Redis hash whose fields are plates and whose values are
+// JSON arrays of entries for that plate.
+func LoadHash(store HashReader, hashKey string) ([]Entry, error) {
+	fields, err := store.GetAllFields(hashKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for plate, value := range fields {
+		var plateEntries []Entry
+		if err := json.Unmarshal([]byte(value), &plateEntries); err != nil {
+			return nil, fmt.Errorf("error decoding registry entries of %s: %v", plate, err)
+		}
+		for _, entry := range plateEntries {
+			entry.Plate = plate
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
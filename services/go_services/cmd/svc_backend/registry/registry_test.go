@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockHashReader map[string]string
+
+func (m mockHashReader) GetAllFields(hashKey string) (map[string]string, error) {
+	return m, nil
+}
+
+func TestRegistry_Classify(t *testing.T) {
+	day := func(d string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", d)
+		return parsed
+	}
+	registry, err := NewRegistry([]Entry{
+		{Plate: "staff-1", Class: Whitelist},
+		{Plate: "TENANT1", Class: Permit, ValidFrom: day("2024-09-01"), ValidUntil: day("2024-09-30")},
+		{Plate: "STOLEN1", Class: Blacklist},
+		{Plate: "STOLEN1", Class: Permit},
+	}, strings.ToUpper)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		plate    string
+		at       time.Time
+		expected Class
+	}{
+		{"Unregistered", "ABC123", day("2024-09-11"), Visitor},
+		{"Whitelisted Normalised Plate", "STAFF-1", day("2024-09-11"), Whitelist},
+		{"Permit Within Period", "TENANT1", day("2024-09-11"), Permit},
+		{"Permit Before Period", "TENANT1", day("2024-08-31"), Visitor},
+		{"Permit After Period", "TENANT1", day("2024-10-01"), Visitor},
+		{"Blacklist Wins", "STOLEN1", day("2024-09-11"), Blacklist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, registry.Classify(tt.plate, tt.at))
+		})
+	}
+	assert.Equal(t, 4, registry.Len())
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var registry *Registry
+
+	assert.Equal(t, Visitor, registry.Classify("ABC123", time.Now()))
+}
+
+func TestNewRegistry_UnknownClass(t *testing.T) {
+	_, err := NewRegistry([]Entry{{Plate: "ABC123", Class: "vip"}}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	err := os.WriteFile(path, []byte(`[{"plate": "ABC123", "class": "permit", "valid_until": "2024-12-31T23:59:59Z"}]`), 0o644)
+	assert.NoError(t, err)
+
+	entries, err := LoadFile(path)
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, Permit, entries[0].Class)
+	assert.Equal(t, 2024, entries[0].ValidUntil.Year())
+}
+
+func TestLoadHash(t *testing.T) {
+	entries, err := LoadHash(mockHashReader{
+		"ABC123": `[{"class": "blacklist", "note": "reported stolen"}]`,
+	}, "plate_registry")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{{Plate: "ABC123", Class: Blacklist, Note: "reported stolen"}}, entries)
+
+	_, err = LoadHash(mockHashReader{"ABC123": `not json`}, "plate_registry")
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/metrics"
+	pkgconfig "go_services/pkg/config"
+	"go_services/pkg/logger"
+	"go_services/pkg/rabbitmq"
+	"go_services/pkg/retry"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// configPollInterval is how often the config file is checked for changes.
+const configPollInterval = 2 * time.Second
+
+// liveProcessor hands each message to the current processor, so that a reload can replace the
+// processor while the queue consumer keeps running.
+type liveProcessor struct {
+	current atomic.Pointer[processorRef]
+}
+
+type processorRef struct {
+	rabbitmq.Client
+}
+
+func (l *liveProcessor) set(processor rabbitmq.Client) {
+	l.current.Store(&processorRef{processor})
+}
+
+func (l *liveProcessor) ProcessMessage(msg []byte) error {
+	return l.current.Load().ProcessMessage(msg)
+}
+
+// service runs the processors built from the current configuration and replaces them when the
+// configuration is reloaded.
+type service struct {
+	build           func(cfg *config.Config) (*pipeline, error)
+	setProcessRetry func(policy retry.Policy) // nil when the transport does not retry events
+
+	entry      liveProcessor
+	exit       liveProcessor
+	validation liveProcessor
+
+	replayEntry liveProcessor
+	replayExit  liveProcessor
+
+	mu           sync.Mutex
+	cfg          *config.Config
+	stopOverstay chan struct{}
+}
+
+// apply builds the processors for cfg and switches to them; on error the running ones are kept.
+func (s *service) apply(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyLocked(cfg)
+}
+
+func (s *service) applyLocked(cfg *config.Config) error {
+	p, err := s.build(cfg)
+	if err != nil {
+		return err
+	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("invalid log level %q: %v", cfg.LogLevel, err)
+	}
+
+	s.entry.set(p.entry)
+	s.exit.set(p.exit)
+	s.validation.set(p.validation)
+	s.replayEntry.set(p.replayEntry)
+	s.replayExit.set(p.replayExit)
+	if s.setProcessRetry != nil {
+		s.setProcessRetry(cfg.KafkaProcessRetry())
+	}
+
+	if s.stopOverstay != nil {
+		close(s.stopOverstay)
+		s.stopOverstay = nil
+	}
+	if p.overstay != nil {
+		s.stopOverstay = make(chan struct{})
+		go p.overstay.Run(cfg.OverstayScanInterval, s.stopOverstay)
+		logger.Log.Debug().Msgf("Overstay scanner started, checking every %v", cfg.OverstayScanInterval)
+	} else {
+		logger.Log.Debug().Msg("Overstay detection disabled")
+	}
+
+	s.cfg = cfg
+	return nil
+}
+
+// reload loads the configuration again from args and applies its reloadable settings. Changes of other
+// settings are logged and ignored until restart; an invalid configuration is rejected and the current
+// one kept.
+func (s *service) reload(args []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, _, err := config.LoadConfig(args)
+	if err != nil {
+		// metrics instrumentation: count rejected reloads
+		metrics.ConfigReloads.With(prometheus.Labels{"result": "rejected"}).Inc()
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	fixed, err := pkgconfig.KeepFixed(next, s.cfg)
+	if err != nil {
+		// metrics instrumentation: count rejected reloads
+		metrics.ConfigReloads.With(prometheus.Labels{"result": "rejected"}).Inc()
+		return err
+	}
+	for _, key := range fixed {
+		logger.Log.Warn().Msgf("Ignoring the changed %s until restart", key)
+	}
+
+	if err := s.applyLocked(next); err != nil {
+		// metrics instrumentation: count rejected reloads
+		metrics.ConfigReloads.With(prometheus.Labels{"result": "rejected"}).Inc()
+		return err
+	}
+
+	// metrics instrumentation: count applied reloads
+	metrics.ConfigReloads.With(prometheus.Labels{"result": "applied"}).Inc()
+	logger.Log.Info().Msg("Configuration reloaded")
+	printConfig(next)
+	return nil
+}
+
+// watchReloads reloads the configuration from args on SIGHUP and whenever the config file changes.
+func (s *service) watchReloads(args []string, file string) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	var poll <-chan time.Time
+	var last fileVersion
+	if file != "" {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+		last, _ = statFile(file)
+	}
+
+	for {
+		select {
+		case <-hangups:
+			logger.Log.Info().Msg("Received SIGHUP, reloading configuration")
+		case <-poll:
+			version, err := statFile(file)
+			if err != nil || version == last {
+				continue
+			}
+			last = version
+			logger.Log.Info().Msgf("Configuration file %s changed, reloading", file)
+		}
+
+		if err := s.reload(args); err != nil {
+			logger.Log.Error().Err(err).Msg("Rejected configuration reload, keeping the current configuration")
+		}
+	}
+}
+
+// fileVersion identifies the content of a file by its modification time and size.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
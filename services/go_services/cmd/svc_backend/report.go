@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/reports"
+	"go_services/pkg/logger"
+)
+
+// runReport implements the "report" subcommand: it aggregates the archived sessions into
+// daily and/or monthly statistics and writes them as CSV and JSON files.
+func runReport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	outDir := flags.String("out", cfg.ReportOutputDir, "directory the report files are written to")
+	period := flags.String("period", "all", "report period: daily, monthly or all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var periods []reports.Period
+	switch *period {
+	case "all":
+		periods = []reports.Period{reports.Daily, reports.Monthly}
+	case string(reports.Daily), string(reports.Monthly):
+		periods = []reports.Period{reports.Period(*period)}
+	default:
+		return fmt.Errorf("unknown report period %q", *period)
+	}
+
+	store, closeStore, err := openStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	archive := &reports.Archive{Store: store, MaxItems: cfg.ArchiveMaxItems}
+	sessions, err := archive.LoadSessions()
+	if err != nil {
+		return err
+	}
+	orphans, err := archive.LoadOrphanExits()
+	if err != nil {
+		return err
+	}
+	logger.Log.Info().Msgf("Generating reports from %d sessions and %d orphan exits", len(sessions), len(orphans))
+
+	for _, p := range periods {
+		stats, err := reports.Aggregate(sessions, orphans, p)
+		if err != nil {
+			return err
+		}
+		if err := reports.WriteFiles(*outDir, p, stats); err != nil {
+			return err
+		}
+		logger.Log.Info().Msgf("Wrote %s report to %s", p, *outDir)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package reports
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Period selects the granularity of an aggregate report.
+type Period string
+
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+// Stats holds the aggregated figures for one day or month.
+type Stats struct {
+	Period         string  `json:"period"`
+	Visits         int     `json:"visits"`
+	UniquePlates   int     `json:"unique_plates"`
+	AvgStaySeconds float64 `json:"avg_stay_seconds"`
+	P50StaySeconds float64 `json:"p50_stay_seconds"`
+	P90StaySeconds float64 `json:"p90_stay_seconds"`
+	P95StaySeconds float64 `json:"p95_stay_seconds"`
+	RevenueCents   int64   `json:"revenue_cents"`
+	OrphanExits    int     `json:"orphan_exits"`
+}
+
+// bucket collects the raw values of one period before they are reduced to Stats.
+type bucket struct {
+	stays   []float64
+	plates  map[string]struct{}
+	revenue int64
+	orphans int
+}
+
+// Aggregate groups completed sessions and orphan exits by the UTC date of the exit and
+// returns one Stats entry per period, ordered chronologically.
+func Aggregate(sessions []models.ParkingLog, orphans []models.ExitEvent, period Period) ([]Stats, error) {
+	layout, err := periodLayout(period)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(map[string]*bucket)
+	get := func(t time.Time) *bucket {
+		key := t.UTC().Format(layout)
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{plates: make(map[string]struct{})}
+			buckets[key] = b
+		}
+		return b
+	}
+
+	for _, session := range sessions {
+		b := get(session.ExitDateTime)
+		b.stays = append(b.stays, session.ExitDateTime.Sub(session.EntryDateTime).Seconds())
+		b.plates[session.VehiclePlate] = struct{}{}
+		b.revenue += session.FeeCents
+	}
+	for _, orphan := range orphans {
+		get(orphan.ExitDateTime).orphans++
+	}
+
+	keys := make([]string, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	stats := make([]Stats, 0, len(keys))
+	for _, key := range keys {
+		b := buckets[key]
+		sort.Float64s(b.stays)
+		stats = append(stats, Stats{
+			Period:         key,
+			Visits:         len(b.stays),
+			UniquePlates:   len(b.plates),
+			AvgStaySeconds: average(b.stays),
+			P50StaySeconds: percentile(b.stays, 50),
+			P90StaySeconds: percentile(b.stays, 90),
+			P95StaySeconds: percentile(b.stays, 95),
+			RevenueCents:   b.revenue,
+			OrphanExits:    b.orphans,
+		})
+	}
+	return stats, nil
+}
+
+func periodLayout(period Period) (string, error) {
+	switch period {
+	case Daily:
+		return "2006-01-02", nil
+	case Monthly:
+		return "2006-01", nil
+	default:
+		return "", fmt.Errorf("unknown report period %q", period)
+	}
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile of an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,111 @@
+package reports
+
+import (
+	"bytes"
+	"go_services/cmd/svc_backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func session(plate string, entry string, stay time.Duration, fee int64) models.ParkingLog {
+	entryDateTime, _ := time.Parse(time.RFC3339, entry)
+	return models.ParkingLog{
+		VehiclePlate:  plate,
+		EntryDateTime: entryDateTime,
+		ExitDateTime:  entryDateTime.Add(stay),
+		Duration:      stay.String(),
+		FeeCents:      fee,
+	}
+}
+
+func orphan(plate string, exit string) models.ExitEvent {
+	exitDateTime, _ := time.Parse(time.RFC3339, exit)
+	return models.ExitEvent{VehiclePlate: plate, ExitDateTime: exitDateTime}
+}
+
+func TestAggregate_Daily(t *testing.T) {
+	sessions := []models.ParkingLog{
+		session("ABC123", "2024-09-11T08:00:00Z", 1*time.Hour, 200),
+		session("ABC123", "2024-09-11T12:00:00Z", 2*time.Hour, 400),
+		session("XYZ789", "2024-09-11T09:00:00Z", 3*time.Hour, 600),
+		session("LMN456", "2024-09-12T10:00:00Z", 30*time.Minute, 200),
+	}
+	orphans := []models.ExitEvent{
+		orphan("QQQ111", "2024-09-12T11:00:00Z"),
+		orphan("QQQ222", "2024-09-13T11:00:00Z"),
+	}
+
+	stats, err := Aggregate(sessions, orphans, Daily)
+
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+
+	assert.Equal(t, Stats{
+		Period:         "2024-09-11",
+		Visits:         3,
+		UniquePlates:   2,
+		AvgStaySeconds: 7200,
+		P50StaySeconds: 7200,
+		P90StaySeconds: 10800,
+		P95StaySeconds: 10800,
+		RevenueCents:   1200,
+		OrphanExits:    0,
+	}, stats[0])
+	assert.Equal(t, "2024-09-12", stats[1].Period)
+	assert.Equal(t, 1, stats[1].Visits)
+	assert.Equal(t, 1, stats[1].OrphanExits)
+	assert.Equal(t, "2024-09-13", stats[2].Period)
+	assert.Equal(t, 0, stats[2].Visits)
+	assert.Equal(t, 1, stats[2].OrphanExits)
+}
+
+func TestAggregate_Monthly(t *testing.T) {
+	sessions := []models.ParkingLog{
+		session("ABC123", "2024-09-11T08:00:00Z", 1*time.Hour, 200),
+		session("ABC123", "2024-09-30T23:30:00Z", 1*time.Hour, 200), // exits in October
+		session("XYZ789", "2024-10-02T09:00:00Z", 2*time.Hour, 400),
+	}
+
+	stats, err := Aggregate(sessions, nil, Monthly)
+
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "2024-09", stats[0].Period)
+	assert.Equal(t, 1, stats[0].Visits)
+	assert.Equal(t, "2024-10", stats[1].Period)
+	assert.Equal(t, 2, stats[1].Visits)
+	assert.Equal(t, 2, stats[1].UniquePlates)
+	assert.Equal(t, int64(600), stats[1].RevenueCents)
+}
+
+func TestAggregate_UnknownPeriod(t *testing.T) {
+	stats, err := Aggregate(nil, nil, Period("weekly"))
+
+	assert.Error(t, err)
+	assert.Nil(t, stats)
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, 5.0, percentile(values, 50))
+	assert.Equal(t, 9.0, percentile(values, 90))
+	assert.Equal(t, 10.0, percentile(values, 95))
+	assert.Equal(t, 0.0, percentile(nil, 50))
+}
+
+func TestWriteCSV(t *testing.T) {
+	stats := []Stats{{Period: "2024-09", Visits: 2, UniquePlates: 1, AvgStaySeconds: 90, RevenueCents: 400, OrphanExits: 1}}
+
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, stats)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, strings.Join(csvHeader, ","), lines[0])
+	assert.Equal(t, "2024-09,2,1,90.00,0.00,0.00,0.00,400,1", lines[1])
+}
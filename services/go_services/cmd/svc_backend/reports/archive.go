@@ -0,0 +1,86 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+)
+
+const (
+	CompletedSessionsKey = "completed_sessions"
+	OrphanExitsKey       = "orphan_exits"
+)
+
+// ListStore defines the interface for appending to, trimming and reading back a list of items.
+type ListStore interface {
+	AppendToList(listKey string, item string) error
+	GetListItems(listKey string) ([]string, error)
+	TrimList(listKey string, maxItems int) error
+}
+
+// Archive keeps completed parking sessions and orphan exits so that reports can be generated later.
+// It implements processors.SummaryPoster and processors.OrphanRecorder.
+type Archive struct {
+	Store    ListStore
+	MaxItems int // the most recent items kept per list; 0 keeps all
+}
+
+// PostSummary archives a completed parking summary.
+func (a *Archive) PostSummary(data interface{}) error {
+	return a.appendJSON(CompletedSessionsKey, data)
+}
+
+// RecordOrphanExit archives an exit event that had no matching entry.
+func (a *Archive) RecordOrphanExit(event models.ExitEvent) error {
+	return a.appendJSON(OrphanExitsKey, event)
+}
+
+// LoadSessions returns all archived parking summaries.
+func (a *Archive) LoadSessions() ([]models.ParkingLog, error) {
+	items, err := a.Store.GetListItems(CompletedSessionsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.ParkingLog, 0, len(items))
+	for _, item := range items {
+		var session models.ParkingLog
+		if err := json.Unmarshal([]byte(item), &session); err != nil {
+			return nil, fmt.Errorf("error decoding archived session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// LoadOrphanExits returns all archived orphan exit events.
+func (a *Archive) LoadOrphanExits() ([]models.ExitEvent, error) {
+	items, err := a.Store.GetListItems(OrphanExitsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]models.ExitEvent, 0, len(items))
+	for _, item := range items {
+		var orphan models.ExitEvent
+		if err := json.Unmarshal([]byte(item), &orphan); err != nil {
+			return nil, fmt.Errorf("error decoding archived orphan exit: %w", err)
+		}
+		orphans = append(orphans, orphan)
+	}
+	return orphans, nil
+}
+
+func (a *Archive) appendJSON(listKey string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %v", err)
+	}
+	if err := a.Store.AppendToList(listKey, string(body)); err != nil {
+		return err
+	}
+	if a.MaxItems > 0 {
+		return a.Store.TrimList(listKey, a.MaxItems)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package reports
+
+import (
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/memstore"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive_MaxItems(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxItems       int
+		expectedPlates []string
+	}{
+		{name: "Unlimited", maxItems: 0, expectedPlates: []string{"P1", "P2", "P3"}},
+		{name: "Keeps Most Recent", maxItems: 2, expectedPlates: []string{"P2", "P3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := &Archive{Store: memstore.New(), MaxItems: tt.maxItems}
+			for _, plate := range []string{"P1", "P2", "P3"} {
+				assert.NoError(t, archive.PostSummary(models.ParkingLog{VehiclePlate: plate}))
+				assert.NoError(t, archive.RecordOrphanExit(models.ExitEvent{VehiclePlate: plate}))
+			}
+
+			sessions, err := archive.LoadSessions()
+			assert.NoError(t, err)
+			orphans, err := archive.LoadOrphanExits()
+			assert.NoError(t, err)
+			var sessionPlates, orphanPlates []string
+			for i := range sessions {
+				sessionPlates = append(sessionPlates, sessions[i].VehiclePlate)
+				orphanPlates = append(orphanPlates, orphans[i].VehiclePlate)
+			}
+			assert.Equal(t, tt.expectedPlates, sessionPlates)
+			assert.Equal(t, tt.expectedPlates, orphanPlates)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var csvHeader = []string{
+	"period", "visits", "unique_plates", "avg_stay_seconds",
+	"p50_stay_seconds", "p90_stay_seconds", "p95_stay_seconds",
+	"revenue_cents", "orphan_exits",
+}
+
+// WriteCSV writes the stats as CSV with a header row.
+func WriteCSV(w io.Writer, stats []Stats) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) }
+	for _, s := range stats {
+		record := []string{
+			s.Period,
+			strconv.Itoa(s.Visits),
+			strconv.Itoa(s.UniquePlates),
+			formatFloat(s.AvgStaySeconds),
+			formatFloat(s.P50StaySeconds),
+			formatFloat(s.P90StaySeconds),
+			formatFloat(s.P95StaySeconds),
+			strconv.FormatInt(s.RevenueCents, 10),
+			strconv.Itoa(s.OrphanExits),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the stats as an indented JSON array.
+func WriteJSON(w io.Writer, stats []Stats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
+
+// WriteFiles writes <period>_report.csv and <period>_report.json into dir.
+func WriteFiles(dir string, period Period, stats []Stats) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating report directory: %v", err)
+	}
+
+	writers := map[string]func(io.Writer, []Stats) error{
+		"csv":  WriteCSV,
+		"json": WriteJSON,
+	}
+	for ext, write := range writers {
+		path := filepath.Join(dir, fmt.Sprintf("%s_report.%s", period, ext))
+		if err := writeFile(path, stats, write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, stats []Stats, write func(io.Writer, []Stats) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := write(file, stats); err != nil {
+		return fmt.Errorf("error writing report file %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package sessions
+
+import (
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in memory with the same semantics as RedisStore. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+// NewMemoryStore creates an empty in-memory session store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]models.Session)}
+}
+
+// OpenSession records the entry of a session, replacing any earlier entry of the plate.
+func (s *MemoryStore) OpenSession(session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.sessions[session.VehiclePlate]
+	stored.VehiclePlate = session.VehiclePlate
+	stored.RawEntryPlate = session.RawEntryPlate
+	stored.EntryDateTime = session.EntryDateTime
+	stored.EntryCamera = copyCameraRead(session.EntryCamera)
+	s.sessions[session.VehiclePlate] = stored
+	return nil
+}
+
+// CloseSession writes the exit time and returns the session it closes. A plate without an entry
+// keeps the exit, a session that is already closed keeps its exit and an exit older than the entry
+// is ignored; in these cases the error wraps ErrNoOpenSession.
+func (s *MemoryStore) CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.sessions[vehiclePlate]
+	switch {
+	case !exists || stored.EntryDateTime.IsZero():
+		stored.VehiclePlate = vehiclePlate
+		stored.ExitDateTime = exitDateTime
+		s.sessions[vehiclePlate] = stored
+	case isOpen(stored) && !exitDateTime.Before(stored.EntryDateTime):
+		stored.ExitDateTime = exitDateTime
+		s.sessions[vehiclePlate] = stored
+		stored.EntryCamera = copyCameraRead(stored.EntryCamera)
+		return stored, nil
+	}
+	return models.Session{}, fmt.Errorf("%w: %s at %v", ErrNoOpenSession, vehiclePlate, exitDateTime)
+}
+
+// GetOpenSession returns the open session of the plate, if any.
+func (s *MemoryStore) GetOpenSession(vehiclePlate string) (models.Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.sessions[vehiclePlate]
+	if !exists || !isOpen(stored) {
+		return models.Session{}, false, nil
+	}
+	stored.EntryCamera = copyCameraRead(stored.EntryCamera)
+	return openView(stored), true, nil
+}
+
+// GetClosedSession returns the session of the plate if it was closed by the exit at exitDateTime.
+func (s *MemoryStore) GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.sessions[vehiclePlate]
+	if !exists || !closedBy(stored, exitDateTime) {
+		return models.Session{}, false, nil
+	}
+	stored.EntryCamera = copyCameraRead(stored.EntryCamera)
+	return stored, true, nil
+}
+
+// ListOpen returns all open sessions ordered by plate.
+func (s *MemoryStore) ListOpen() ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var open []models.Session
+	for _, stored := range s.sessions {
+		if isOpen(stored) {
+			stored.EntryCamera = copyCameraRead(stored.EntryCamera)
+			open = append(open, openView(stored))
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].VehiclePlate < open[j].VehiclePlate })
+	return open, nil
+}
+
+// copyCameraRead keeps callers from sharing the stored camera read.
+func copyCameraRead(read *models.CameraRead) *models.CameraRead {
+	if read == nil {
+		return nil
+	}
+	copied := *read
+	return &copied
+}
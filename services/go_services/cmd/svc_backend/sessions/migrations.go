@@ -0,0 +1,175 @@
+package sessions
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go_services/pkg/logger"
+)
+
+// The SQL dialects the session store supports, named like the sql_driver setting.
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// migration is one versioned step of the SQL schema. The statements are plain SQL understood by both
+// SQLite and Postgres; steps that differ between them give the statements of each dialect instead.
+type migration struct {
+	version    int
+	statements []string
+	dialects   map[string][]string
+}
+
+// migrations are applied in order; released versions must never be changed, only appended to.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE parking_sessions (
+				vehicle_plate      TEXT NOT NULL,
+				entry_date_time    TEXT NOT NULL,
+				exit_date_time     TEXT,
+				entry_camera_id    TEXT NOT NULL DEFAULT '',
+				entry_snapshot_uri TEXT NOT NULL DEFAULT '',
+				entry_confidence   DOUBLE PRECISION NOT NULL DEFAULT 0,
+				PRIMARY KEY (vehicle_plate, entry_date_time)
+			)`,
+			`CREATE UNIQUE INDEX parking_sessions_one_open_per_plate ON parking_sessions (vehicle_plate) WHERE exit_date_time IS NULL`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE TABLE completed_sessions (
+				vehicle_plate      TEXT NOT NULL,
+				entry_date_time    TEXT NOT NULL,
+				exit_date_time     TEXT NOT NULL,
+				duration_seconds   BIGINT NOT NULL,
+				fee_cents          BIGINT NOT NULL,
+				status             TEXT NOT NULL DEFAULT '',
+				classification     TEXT NOT NULL DEFAULT '',
+				matched_exit_plate TEXT NOT NULL DEFAULT '',
+				recorded_at        TEXT NOT NULL,
+				PRIMARY KEY (vehicle_plate, entry_date_time)
+			)`,
+			`CREATE INDEX completed_sessions_exit ON completed_sessions (exit_date_time)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE parking_sessions ADD COLUMN entry_raw_plate TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		// times are kept in timestamp columns; SQLite cannot change column types, so its tables are rebuilt
+		version: 4,
+		dialects: map[string][]string{
+			DialectSQLite: {
+				`CREATE TABLE parking_sessions_v4 (
+					vehicle_plate      TEXT NOT NULL,
+					entry_date_time    TIMESTAMP NOT NULL,
+					exit_date_time     TIMESTAMP,
+					entry_camera_id    TEXT NOT NULL DEFAULT '',
+					entry_snapshot_uri TEXT NOT NULL DEFAULT '',
+					entry_confidence   DOUBLE PRECISION NOT NULL DEFAULT 0,
+					entry_raw_plate    TEXT NOT NULL DEFAULT '',
+					PRIMARY KEY (vehicle_plate, entry_date_time)
+				)`,
+				`INSERT INTO parking_sessions_v4 SELECT vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate FROM parking_sessions`,
+				`DROP TABLE parking_sessions`,
+				`ALTER TABLE parking_sessions_v4 RENAME TO parking_sessions`,
+				`CREATE UNIQUE INDEX parking_sessions_one_open_per_plate ON parking_sessions (vehicle_plate) WHERE exit_date_time IS NULL`,
+				`CREATE TABLE completed_sessions_v4 (
+					vehicle_plate      TEXT NOT NULL,
+					entry_date_time    TIMESTAMP NOT NULL,
+					exit_date_time     TIMESTAMP NOT NULL,
+					duration_seconds   BIGINT NOT NULL,
+					fee_cents          BIGINT NOT NULL,
+					status             TEXT NOT NULL DEFAULT '',
+					classification     TEXT NOT NULL DEFAULT '',
+					matched_exit_plate TEXT NOT NULL DEFAULT '',
+					recorded_at        TIMESTAMP NOT NULL,
+					PRIMARY KEY (vehicle_plate, entry_date_time)
+				)`,
+				`INSERT INTO completed_sessions_v4 SELECT * FROM completed_sessions`,
+				`DROP TABLE completed_sessions`,
+				`ALTER TABLE completed_sessions_v4 RENAME TO completed_sessions`,
+				`CREATE INDEX completed_sessions_exit ON completed_sessions (exit_date_time)`,
+			},
+			DialectPostgres: {
+				`ALTER TABLE parking_sessions
+					ALTER COLUMN entry_date_time TYPE TIMESTAMPTZ USING entry_date_time::timestamptz,
+					ALTER COLUMN exit_date_time TYPE TIMESTAMPTZ USING exit_date_time::timestamptz`,
+				`ALTER TABLE completed_sessions
+					ALTER COLUMN entry_date_time TYPE TIMESTAMPTZ USING entry_date_time::timestamptz,
+					ALTER COLUMN exit_date_time TYPE TIMESTAMPTZ USING exit_date_time::timestamptz,
+					ALTER COLUMN recorded_at TYPE TIMESTAMPTZ USING recorded_at::timestamptz`,
+			},
+		},
+	},
+	{
+		// the exit of a plate without an entry, kept until the entry arrives; see SQLStore.CloseSession
+		version: 5,
+		dialects: map[string][]string{
+			DialectSQLite: {
+				`CREATE TABLE unmatched_exits (vehicle_plate TEXT PRIMARY KEY, exit_date_time TIMESTAMP NOT NULL)`,
+			},
+			DialectPostgres: {
+				`CREATE TABLE unmatched_exits (vehicle_plate TEXT PRIMARY KEY, exit_date_time TIMESTAMPTZ NOT NULL)`,
+			},
+		},
+	},
+}
+
+// Migrate brings the schema of db, in the given dialect, up to date, applying each missing migration in
+// its own transaction.
+func Migrate(db *sql.DB, dialect string) error {
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return fmt.Errorf("unknown SQL dialect %q", dialect)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %v", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("error reading schema version: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m, dialect); err != nil {
+			return fmt.Errorf("error applying migration %d: %w", m.version, err)
+		}
+		logger.Log.Info().Msgf("Applied session store migration %d", m.version)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration, dialect string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := m.statements
+	if m.dialects != nil {
+		statements = m.dialects[dialect]
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, m.version, formatTime(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
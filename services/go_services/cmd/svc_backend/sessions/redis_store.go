@@ -0,0 +1,227 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/logger"
+	"go_services/pkg/redis"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	entryField    = "entry_date_time"
+	exitField     = "exit_date_time"
+	rawEntryField = "entry_raw_plate"
+)
+
+// HashStore defines the hash operations the Redis session store is built on.
+type HashStore interface {
+	AddFieldsToHash(hashKey string, fields map[string]string) error
+	GetAllFields(hashKey string) (map[string]string, error)
+	ScanHashKeys(pattern string) ([]string, error)
+	CloseSession(hashKey string, entryField string, exitField string, exitTime time.Time) (time.Time, error)
+}
+
+// RedisStore keeps each session in a Redis hash keyed by the hash-tagged plate, e.g. "{ABC123}", with
+// the fields entry_date_time, exit_date_time, entry_raw_plate and the entry camera details. The tag keeps a session's
+// keys in one Redis Cluster slot; sessions stored by earlier versions under the bare plate are moved by
+// MigrateBareKeys. memstore.Store provides the same hashes in memory.
+type RedisStore struct {
+	Client HashStore
+}
+
+// OpenSession records the entry of a session, replacing any earlier entry of the plate.
+func (s *RedisStore) OpenSession(session models.Session) error {
+	fields := cameraFields("entry", session.EntryCamera)
+	fields[entryField] = session.EntryDateTime.Format(time.RFC3339Nano)
+	fields[rawEntryField] = session.RawEntryPlate
+	return s.Client.AddFieldsToHash(sessionKey(session.VehiclePlate), fields)
+}
+
+// CloseSession writes the exit time and returns the session it closes; see redis.RedisClient.CloseSession.
+func (s *RedisStore) CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+	hashKey := sessionKey(vehiclePlate)
+	entryDateTime, err := s.Client.CloseSession(hashKey, entryField, exitField, exitDateTime)
+	if errors.Is(err, redis.ErrFieldNotFound) {
+		return models.Session{}, fmt.Errorf("%w: %s at %v", ErrNoOpenSession, vehiclePlate, exitDateTime)
+	}
+	if errors.Is(err, redis.ErrWriteFailed) {
+		return models.Session{}, fmt.Errorf("%w: %v", ErrSessionWrite, err)
+	}
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session := models.Session{VehiclePlate: vehiclePlate, EntryDateTime: entryDateTime, ExitDateTime: exitDateTime}
+	if fields, err := s.Client.GetAllFields(hashKey); err != nil {
+		logger.Log.Error().Err(err).Msg("Failed reading entry camera details")
+	} else {
+		session.RawEntryPlate = fields[rawEntryField]
+		session.EntryCamera = cameraReadFromFields("entry", fields)
+	}
+	return session, nil
+}
+
+// GetOpenSession returns the open session of the plate, if any.
+func (s *RedisStore) GetOpenSession(vehiclePlate string) (models.Session, bool, error) {
+	fields, err := s.Client.GetAllFields(sessionKey(vehiclePlate))
+	if err != nil {
+		return models.Session{}, false, fmt.Errorf("error retrieving session: %w", err)
+	}
+	session, ok := sessionFromFields(vehiclePlate, fields)
+	if !ok || !isOpen(session) {
+		return models.Session{}, false, nil
+	}
+	return openView(session), true, nil
+}
+
+// GetClosedSession returns the session of the plate if it was closed by the exit at exitDateTime.
+func (s *RedisStore) GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error) {
+	fields, err := s.Client.GetAllFields(sessionKey(vehiclePlate))
+	if err != nil {
+		return models.Session{}, false, fmt.Errorf("error retrieving session: %w", err)
+	}
+	session, ok := sessionFromFields(vehiclePlate, fields)
+	if !ok || !closedBy(session, exitDateTime) {
+		return models.Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// ListOpen returns all open sessions ordered by plate, scanning the session keys without blocking
+// Redis; the other hashes in the store do not match the hash-tagged key pattern and are not read.
+func (s *RedisStore) ListOpen() ([]models.Session, error) {
+	keys, err := s.Client.ScanHashKeys(sessionKey("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var open []models.Session
+	for _, hashKey := range keys {
+		fields, err := s.Client.GetAllFields(hashKey)
+		if err != nil {
+			return nil, err
+		}
+		session, ok := sessionFromFields(plateOfKey(hashKey), fields)
+		if ok && isOpen(session) {
+			open = append(open, openView(session))
+		}
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].VehiclePlate < open[j].VehiclePlate })
+	return open, nil
+}
+
+// sessionKey returns the key of the session hash of a plate.
+func sessionKey(vehiclePlate string) string {
+	return redis.HashTag(vehiclePlate)
+}
+
+// plateOfKey returns the plate of a session hash key.
+func plateOfKey(hashKey string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(hashKey, "{"), "}")
+}
+
+// sessionFromFields restores a session from its hash fields; hashes without a valid entry are not sessions.
+func sessionFromFields(vehiclePlate string, fields map[string]string) (models.Session, bool) {
+	entryDateTime, err := time.Parse(time.RFC3339, fields[entryField])
+	if err != nil {
+		return models.Session{}, false
+	}
+	session := models.Session{
+		VehiclePlate:  vehiclePlate,
+		RawEntryPlate: fields[rawEntryField],
+		EntryDateTime: entryDateTime,
+		EntryCamera:   cameraReadFromFields("entry", fields),
+	}
+	if exitDateTime, err := time.Parse(time.RFC3339, fields[exitField]); err == nil {
+		session.ExitDateTime = exitDateTime
+	}
+	return session, true
+}
+
+// cameraFields returns the session hash fields that keep a camera read, prefixed with the event type.
+func cameraFields(prefix string, read *models.CameraRead) map[string]string {
+	if read == nil {
+		read = &models.CameraRead{}
+	}
+	confidence := ""
+	if read.Confidence > 0 {
+		confidence = strconv.FormatFloat(read.Confidence, 'f', -1, 64)
+	}
+	return map[string]string{
+		prefix + "_camera_id":    read.CameraID,
+		prefix + "_snapshot_uri": read.SnapshotURI,
+		prefix + "_confidence":   confidence,
+	}
+}
+
+// cameraReadFromFields restores a camera read from session hash fields, or nil when none was stored.
+func cameraReadFromFields(prefix string, fields map[string]string) *models.CameraRead {
+	read := models.CameraRead{
+		CameraID:    fields[prefix+"_camera_id"],
+		SnapshotURI: fields[prefix+"_snapshot_uri"],
+	}
+	if confidence, err := strconv.ParseFloat(fields[prefix+"_confidence"], 64); err == nil {
+		read.Confidence = confidence
+	}
+	if read == (models.CameraRead{}) {
+		return nil
+	}
+	return &read
+}
+
+// KeyMigrationStore defines the operations MigrateBareKeys needs on top of the session hashes.
+type KeyMigrationStore interface {
+	HashStore
+	DeleteKey(key string) error
+}
+
+// MigrateBareKeys moves the sessions that earlier versions kept under the plate as read by the camera,
+// e.g. "ABC-123", to the hash-tagged key of the normalized plate and returns how many were moved. A
+// plate that entered again since keeps its newer session and the old one is dropped. Hashes without
+// an entry time, like the other data in the store, are left alone.
+func MigrateBareKeys(store KeyMigrationStore, normalize func(string) string) (int, error) {
+	keys, err := store.ScanHashKeys("*")
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, hashKey := range keys {
+		if strings.HasPrefix(hashKey, "{") {
+			continue
+		}
+		fields, err := store.GetAllFields(hashKey)
+		if err != nil {
+			return moved, err
+		}
+		if fields[entryField] == "" {
+			continue
+		}
+
+		vehiclePlate := normalize(hashKey)
+		current, err := store.GetAllFields(sessionKey(vehiclePlate))
+		if err != nil {
+			return moved, err
+		}
+		if current[entryField] == "" {
+			if fields[rawEntryField] == "" {
+				fields[rawEntryField] = hashKey
+			}
+			if err := store.AddFieldsToHash(sessionKey(vehiclePlate), fields); err != nil {
+				return moved, err
+			}
+			moved++
+		} else {
+			logger.Log.Info().Msgf("Dropping the earlier session of %s, the plate entered again", hashKey)
+		}
+		if err := store.DeleteKey(hashKey); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,34 @@
+package sessions
+
+import (
+	"errors"
+	"go_services/cmd/svc_backend/models"
+	"time"
+)
+
+// ErrNoOpenSession is returned when a plate has no open session to close.
+var ErrNoOpenSession = errors.New("no open session")
+
+// ErrSessionWrite is wrapped by errors of CloseSession that failed writing the exit; other failures
+// come from reading the session.
+var ErrSessionWrite = errors.New("session write failed")
+
+// isOpen reports whether a stored session is open: it has an entry and either no exit or an exit that
+// is not after the entry, which is left over from the plate's previous session.
+func isOpen(session models.Session) bool {
+	if session.EntryDateTime.IsZero() {
+		return false
+	}
+	return session.ExitDateTime.IsZero() || !session.ExitDateTime.After(session.EntryDateTime)
+}
+
+// closedBy reports whether a stored session has an entry and was closed by the exit at exitDateTime.
+func closedBy(session models.Session, exitDateTime time.Time) bool {
+	return !session.EntryDateTime.IsZero() && !isOpen(session) && session.ExitDateTime.Equal(exitDateTime)
+}
+
+// openView returns the session as seen while it is open, without a left over exit time.
+func openView(session models.Session) models.Session {
+	session.ExitDateTime = time.Time{}
+	return session
+}
@@ -0,0 +1,202 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/models"
+	"time"
+)
+
+// sqlTimeLayout is the fixed width UTC layout of the times written to SQLite, which keeps timestamps as
+// text, so that they also compare correctly as text.
+const sqlTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SQLStore keeps sessions in the parking_sessions table of a SQLite or Postgres database; see Migrate
+// for the schema. It also implements processors.SummaryPoster, recording billed sessions in the
+// completed_sessions table so that they can be queried with SQL.
+type SQLStore struct {
+	DB      *sql.DB
+	Dialect string // DialectSQLite or DialectPostgres
+}
+
+// OpenSession records the entry of a session, replacing the entry of the plate's open session if it
+// has one. Repeating the entry of a closed session leaves it closed, and an entry that arrives after
+// an exit kept by CloseSession is closed by that exit.
+func (s *SQLStore) OpenSession(session models.Session) error {
+	camera := session.EntryCamera
+	if camera == nil {
+		camera = &models.CameraRead{}
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error opening session: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE parking_sessions
+		SET entry_date_time = $2, entry_camera_id = $3, entry_snapshot_uri = $4, entry_confidence = $5, entry_raw_plate = $6
+		WHERE vehicle_plate = $1 AND exit_date_time IS NULL`,
+		session.VehiclePlate, s.timeArg(session.EntryDateTime), camera.CameraID, camera.SnapshotURI, camera.Confidence, session.RawEntryPlate)
+	if err != nil {
+		return fmt.Errorf("error opening session: %v", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error opening session: %v", err)
+	}
+	if updated > 0 {
+		return tx.Commit()
+	}
+
+	var exit sql.NullTime
+	if err := tx.QueryRow(`DELETE FROM unmatched_exits WHERE vehicle_plate = $1 RETURNING exit_date_time`,
+		session.VehiclePlate).Scan(&exit); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error opening session: %v", err)
+	}
+	var exitArg any
+	if exit.Valid && exit.Time.After(session.EntryDateTime) {
+		exitArg = s.timeArg(exit.Time)
+	}
+	if _, err := tx.Exec(`INSERT INTO parking_sessions
+		(vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (vehicle_plate, entry_date_time) DO NOTHING`,
+		session.VehiclePlate, s.timeArg(session.EntryDateTime), exitArg, camera.CameraID, camera.SnapshotURI, camera.Confidence, session.RawEntryPlate); err != nil {
+		return fmt.Errorf("error opening session: %v", err)
+	}
+	return tx.Commit()
+}
+
+// CloseSession sets the exit time of the plate's open session in a single statement and returns the
+// closed session. Exits older than the entry do not close it. Like the other stores, the exit of a plate
+// that never entered is kept in unmatched_exits, so that an entry arriving late is closed by it.
+func (s *SQLStore) CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error) {
+	row := s.DB.QueryRow(`UPDATE parking_sessions SET exit_date_time = $2
+		WHERE vehicle_plate = $1 AND exit_date_time IS NULL AND entry_date_time <= $2
+		RETURNING vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate`,
+		vehiclePlate, s.timeArg(exitDateTime))
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.DB.Exec(`INSERT INTO unmatched_exits (vehicle_plate, exit_date_time)
+			SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM parking_sessions WHERE vehicle_plate = $1)
+			ON CONFLICT (vehicle_plate) DO UPDATE SET exit_date_time = excluded.exit_date_time`,
+			vehiclePlate, s.timeArg(exitDateTime)); err != nil {
+			return models.Session{}, fmt.Errorf("%w: error keeping exit without entry: %v", ErrSessionWrite, err)
+		}
+		return models.Session{}, fmt.Errorf("%w: %s at %v", ErrNoOpenSession, vehiclePlate, exitDateTime)
+	}
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%w: error closing session: %v", ErrSessionWrite, err)
+	}
+	return session, nil
+}
+
+// GetOpenSession returns the open session of the plate, if any.
+func (s *SQLStore) GetOpenSession(vehiclePlate string) (models.Session, bool, error) {
+	row := s.DB.QueryRow(`SELECT vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate
+		FROM parking_sessions WHERE vehicle_plate = $1 AND exit_date_time IS NULL`, vehiclePlate)
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, false, nil
+	}
+	if err != nil {
+		return models.Session{}, false, fmt.Errorf("error retrieving session: %v", err)
+	}
+	return session, true, nil
+}
+
+// GetClosedSession returns the session of the plate if it was closed by the exit at exitDateTime.
+func (s *SQLStore) GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error) {
+	row := s.DB.QueryRow(`SELECT vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate
+		FROM parking_sessions WHERE vehicle_plate = $1 AND exit_date_time = $2`, vehiclePlate, s.timeArg(exitDateTime))
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, false, nil
+	}
+	if err != nil {
+		return models.Session{}, false, fmt.Errorf("error retrieving session: %v", err)
+	}
+	return session, true, nil
+}
+
+// ListOpen returns all open sessions ordered by plate.
+func (s *SQLStore) ListOpen() ([]models.Session, error) {
+	rows, err := s.DB.Query(`SELECT vehicle_plate, entry_date_time, exit_date_time, entry_camera_id, entry_snapshot_uri, entry_confidence, entry_raw_plate
+		FROM parking_sessions WHERE exit_date_time IS NULL ORDER BY vehicle_plate`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing open sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var open []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error listing open sessions: %v", err)
+		}
+		open = append(open, session)
+	}
+	return open, rows.Err()
+}
+
+// PostSummary records a completed session with its fee; a repeated summary of the same session
+// replaces the earlier one.
+func (s *SQLStore) PostSummary(data interface{}) error {
+	parkingLog, ok := data.(models.ParkingLog)
+	if !ok {
+		return fmt.Errorf("cannot record %T as a completed session", data)
+	}
+
+	_, err := s.DB.Exec(`INSERT INTO completed_sessions
+		(vehicle_plate, entry_date_time, exit_date_time, duration_seconds, fee_cents, status, classification, matched_exit_plate, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (vehicle_plate, entry_date_time) DO UPDATE SET
+			exit_date_time = excluded.exit_date_time, duration_seconds = excluded.duration_seconds,
+			fee_cents = excluded.fee_cents, status = excluded.status, classification = excluded.classification,
+			matched_exit_plate = excluded.matched_exit_plate, recorded_at = excluded.recorded_at`,
+		parkingLog.VehiclePlate, s.timeArg(parkingLog.EntryDateTime), s.timeArg(parkingLog.ExitDateTime),
+		int64(parkingLog.ExitDateTime.Sub(parkingLog.EntryDateTime).Seconds()), parkingLog.FeeCents,
+		parkingLog.Status, parkingLog.Classification, parkingLog.MatchedExitPlate, s.timeArg(time.Now()))
+	if err != nil {
+		return fmt.Errorf("error recording completed session: %v", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	var exit sql.NullTime
+	var camera models.CameraRead
+	if err := row.Scan(&session.VehiclePlate, &session.EntryDateTime, &exit, &camera.CameraID, &camera.SnapshotURI, &camera.Confidence, &session.RawEntryPlate); err != nil {
+		return models.Session{}, err
+	}
+
+	session.EntryDateTime = session.EntryDateTime.UTC()
+	if exit.Valid {
+		session.ExitDateTime = exit.Time.UTC()
+	}
+	if camera != (models.CameraRead{}) {
+		session.EntryCamera = &camera
+	}
+	return session, nil
+}
+
+// timeArg returns the query argument of a time: the time itself for Postgres, which keeps it in a
+// timestamptz column, and the fixed width text for SQLite.
+func (s *SQLStore) timeArg(t time.Time) any {
+	if s.Dialect == DialectPostgres {
+		return t.UTC()
+	}
+	return formatTime(t)
+}
+
+// formatTime formats a time for storage in the fixed width UTC layout.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqlTimeLayout)
+}
@@ -0,0 +1,80 @@
+package sessions
+
+import (
+	"database/sql"
+	"go_services/cmd/svc_backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate_Idempotent(t *testing.T) {
+	db := openTestDB(t)
+
+	assert.NoError(t, Migrate(db, DialectSQLite))
+
+	var version int
+	assert.NoError(t, db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+}
+
+func TestMigrate_TimestampColumns(t *testing.T) {
+	db, err := sql.Open("sqlite", t.TempDir()+"/sessions.db")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// a database written before times were kept in timestamp columns
+	_, err = db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`)
+	assert.NoError(t, err)
+	for _, m := range migrations[:3] {
+		assert.NoError(t, applyMigration(db, m, DialectSQLite))
+	}
+	_, err = db.Exec(`INSERT INTO parking_sessions (vehicle_plate, entry_date_time, entry_raw_plate) VALUES ('ABC123', $1, 'abc-123')`, formatTime(entryTime))
+	assert.NoError(t, err)
+
+	assert.NoError(t, Migrate(db, DialectSQLite))
+
+	var columnType string
+	assert.NoError(t, db.QueryRow(`SELECT type FROM pragma_table_info('parking_sessions') WHERE name = 'entry_date_time'`).Scan(&columnType))
+	assert.Equal(t, "TIMESTAMP", columnType)
+	store := &SQLStore{DB: db, Dialect: DialectSQLite}
+	session, found, err := store.GetOpenSession("ABC123")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, models.Session{VehiclePlate: "ABC123", RawEntryPlate: "abc-123", EntryDateTime: entryTime}, session)
+	assert.Error(t, Migrate(db, "mysql"))
+}
+
+func TestSQLStore_OnePerOpenPlate(t *testing.T) {
+	db := openTestDB(t)
+
+	_, err := db.Exec(`INSERT INTO parking_sessions (vehicle_plate, entry_date_time) VALUES ('ABC123', $1)`, formatTime(entryTime))
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO parking_sessions (vehicle_plate, entry_date_time) VALUES ('ABC123', $1)`, formatTime(exitTime))
+	assert.Error(t, err, "a plate has at most one open session")
+}
+
+func TestSQLStore_PostSummary(t *testing.T) {
+	store := &SQLStore{DB: openTestDB(t), Dialect: DialectSQLite}
+	parkingLog := models.ParkingLog{
+		VehiclePlate:  "ABC123",
+		EntryDateTime: entryTime,
+		ExitDateTime:  exitTime,
+		FeeCents:      500,
+	}
+
+	assert.NoError(t, store.PostSummary(parkingLog))
+	parkingLog.FeeCents = 300
+	assert.NoError(t, store.PostSummary(parkingLog), "a repeated summary replaces the earlier one")
+	assert.Error(t, store.PostSummary(models.Alert{}))
+
+	var count, duration, revenue int64
+	err := store.DB.QueryRow(`SELECT COUNT(*), SUM(duration_seconds), SUM(fee_cents) FROM completed_sessions
+		WHERE exit_date_time >= $1 AND exit_date_time < $2`,
+		formatTime(exitTime.Truncate(24*time.Hour)), formatTime(exitTime.Truncate(24*time.Hour).Add(24*time.Hour))).Scan(&count, &duration, &revenue)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, int64(7200), duration)
+	assert.Equal(t, int64(300), revenue)
+}
@@ -0,0 +1,274 @@
+package sessions
+
+import (
+	"database/sql"
+	"go_services/cmd/svc_backend/models"
+	"go_services/pkg/memstore"
+	"go_services/pkg/redis"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+// store is the behaviour shared by the session store implementations.
+type store interface {
+	OpenSession(session models.Session) error
+	CloseSession(vehiclePlate string, exitDateTime time.Time) (models.Session, error)
+	GetOpenSession(vehiclePlate string) (models.Session, bool, error)
+	GetClosedSession(vehiclePlate string, exitDateTime time.Time) (models.Session, bool, error)
+	ListOpen() ([]models.Session, error)
+}
+
+func newStores(t *testing.T) map[string]store {
+	server := miniredis.RunT(t)
+	client := &redis.RedisClient{Client: goredis.NewClient(&goredis.Options{Addr: server.Addr()})}
+	return map[string]store{
+		"Redis":        &RedisStore{Client: client},
+		"MemoryHashes": &RedisStore{Client: memstore.New()},
+		"Memory":       NewMemoryStore(),
+		"SQL":          &SQLStore{DB: openTestDB(t), Dialect: DialectSQLite},
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", t.TempDir()+"/sessions.db")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	assert.NoError(t, Migrate(db, DialectSQLite))
+	return db
+}
+
+var (
+	entryTime = time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	exitTime  = entryTime.Add(2 * time.Hour)
+	gateRead  = &models.CameraRead{Confidence: 0.95, CameraID: "gate-1", SnapshotURI: "s3://snapshots/1.jpg"}
+)
+
+func TestStore_OpenAndClose(t *testing.T) {
+	for name, sessionStore := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", RawEntryPlate: "abc-123", EntryDateTime: entryTime, EntryCamera: gateRead}))
+
+			open, found, err := sessionStore.GetOpenSession("ABC123")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, models.Session{VehiclePlate: "ABC123", RawEntryPlate: "abc-123", EntryDateTime: entryTime, EntryCamera: gateRead}, open)
+
+			closed, err := sessionStore.CloseSession("ABC123", exitTime)
+			assert.NoError(t, err)
+			assert.Equal(t, models.Session{VehiclePlate: "ABC123", RawEntryPlate: "abc-123", EntryDateTime: entryTime, ExitDateTime: exitTime, EntryCamera: gateRead}, closed)
+
+			_, found, err = sessionStore.GetOpenSession("ABC123")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			_, err = sessionStore.CloseSession("ABC123", exitTime.Add(time.Minute))
+			assert.ErrorIs(t, err, ErrNoOpenSession, "a session is closed only once")
+		})
+	}
+}
+
+func TestStore_CloseSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		entries       []time.Time
+		exits         []time.Time
+		exit          time.Time
+		expectedEntry time.Time
+		expectedError error
+	}{
+		{
+			name:          "No Entry",
+			exit:          exitTime,
+			expectedError: ErrNoOpenSession,
+		},
+		{
+			name:          "Reentered After Previous Exit",
+			entries:       []time.Time{entryTime.Add(-5 * time.Hour), entryTime},
+			exits:         []time.Time{entryTime.Add(-4 * time.Hour)},
+			exit:          exitTime,
+			expectedEntry: entryTime,
+		},
+		{
+			name:          "Exit Before Entry",
+			entries:       []time.Time{entryTime},
+			exit:          entryTime.Add(-time.Minute),
+			expectedError: ErrNoOpenSession,
+		},
+		{
+			name:          "Reentered At Close Out Time",
+			entries:       []time.Time{entryTime.Add(-5 * time.Hour), entryTime},
+			exits:         []time.Time{entryTime},
+			exit:          exitTime,
+			expectedEntry: entryTime,
+		},
+	}
+
+	for _, tt := range tests {
+		for name, sessionStore := range newStores(t) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				// entries and exits are applied alternately, starting with an entry
+				for i, entry := range tt.entries {
+					assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: entry}))
+					if i < len(tt.exits) {
+						_, err := sessionStore.CloseSession("ABC123", tt.exits[i])
+						assert.NoError(t, err)
+					}
+				}
+
+				closed, err := sessionStore.CloseSession("ABC123", tt.exit)
+
+				if tt.expectedError != nil {
+					assert.ErrorIs(t, err, tt.expectedError)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedEntry, closed.EntryDateTime)
+				assert.Equal(t, tt.exit, closed.ExitDateTime)
+			})
+		}
+	}
+}
+
+func TestStore_EntryAfterExit(t *testing.T) {
+	for name, sessionStore := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := sessionStore.CloseSession("ABC123", exitTime)
+			assert.ErrorIs(t, err, ErrNoOpenSession)
+
+			// the entry arrives after its exit, which is kept and closes it
+			assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: entryTime}))
+
+			_, found, err := sessionStore.GetOpenSession("ABC123")
+			assert.NoError(t, err)
+			assert.False(t, found)
+			open, err := sessionStore.ListOpen()
+			assert.NoError(t, err)
+			assert.Empty(t, open)
+
+			// the plate's next entry is open again
+			assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: exitTime.Add(time.Hour)}))
+			_, found, err = sessionStore.GetOpenSession("ABC123")
+			assert.NoError(t, err)
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestStore_GetClosedSession(t *testing.T) {
+	for name, sessionStore := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "ABC123", EntryDateTime: entryTime, EntryCamera: gateRead}))
+			_, found, err := sessionStore.GetClosedSession("ABC123", exitTime)
+			assert.NoError(t, err)
+			assert.False(t, found, "an open session is not closed")
+
+			_, err = sessionStore.CloseSession("ABC123", exitTime)
+			assert.NoError(t, err)
+			closed, found, err := sessionStore.GetClosedSession("ABC123", exitTime)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, models.Session{VehiclePlate: "ABC123", EntryDateTime: entryTime, ExitDateTime: exitTime, EntryCamera: gateRead}, closed)
+
+			_, found, err = sessionStore.GetClosedSession("ABC123", exitTime.Add(time.Minute))
+			assert.NoError(t, err)
+			assert.False(t, found, "another exit did not close the session")
+
+			_, err = sessionStore.CloseSession("NOENTRY1", exitTime)
+			assert.ErrorIs(t, err, ErrNoOpenSession)
+			_, found, err = sessionStore.GetClosedSession("NOENTRY1", exitTime)
+			assert.NoError(t, err)
+			assert.False(t, found, "an exit without an entry closes no session")
+		})
+	}
+}
+
+func TestStore_ListOpen(t *testing.T) {
+	for name, sessionStore := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, plate := range []string{"OPEN2", "OPEN1", "EXITED1"} {
+				assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: plate, EntryDateTime: entryTime}))
+			}
+			_, err := sessionStore.CloseSession("EXITED1", exitTime)
+			assert.NoError(t, err)
+			_, err = sessionStore.CloseSession("ORPHAN1", exitTime)
+			assert.ErrorIs(t, err, ErrNoOpenSession)
+
+			open, err := sessionStore.ListOpen()
+
+			assert.NoError(t, err)
+			assert.Equal(t, []models.Session{
+				{VehiclePlate: "OPEN1", EntryDateTime: entryTime},
+				{VehiclePlate: "OPEN2", EntryDateTime: entryTime},
+			}, open)
+		})
+	}
+}
+
+// readRecorder records the hashes read from the hash store it wraps.
+type readRecorder struct {
+	*memstore.Store
+	read []string
+}
+
+func (r *readRecorder) GetAllFields(hashKey string) (map[string]string, error) {
+	r.read = append(r.read, hashKey)
+	return r.Store.GetAllFields(hashKey)
+}
+
+func TestRedisStore_ListOpenReadsSessionsOnly(t *testing.T) {
+	client := &readRecorder{Store: memstore.New()}
+	sessionStore := &RedisStore{Client: client}
+	assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "OPEN1", EntryDateTime: entryTime}))
+	assert.NoError(t, client.AddFieldsToHash("overstay_alerted", map[string]string{"OPEN1|" + entryTime.Format(time.RFC3339): "1"}))
+	assert.NoError(t, client.AddFieldsToHash("invoice_numbers:OPEN1", map[string]string{entryTime.Format(time.RFC3339): "1"}))
+
+	open, err := sessionStore.ListOpen()
+
+	assert.NoError(t, err)
+	assert.Len(t, open, 1)
+	assert.Equal(t, []string{"{OPEN1}"}, client.read)
+}
+
+// hashStores returns the hash stores the Redis session store can keep the sessions in.
+func hashStores(t *testing.T) map[string]KeyMigrationStore {
+	server := miniredis.RunT(t)
+	return map[string]KeyMigrationStore{
+		"Redis":        &redis.RedisClient{Client: goredis.NewClient(&goredis.Options{Addr: server.Addr()})},
+		"MemoryHashes": memstore.New(),
+	}
+}
+
+func TestMigrateBareKeys(t *testing.T) {
+	bareEntry := map[string]string{entryField: entryTime.Format(time.RFC3339)}
+
+	for name, client := range hashStores(t) {
+		t.Run(name, func(t *testing.T) {
+			sessionStore := &RedisStore{Client: client}
+			// sessions stored by earlier versions under the plate as read
+			assert.NoError(t, client.AddFieldsToHash("abc-123", bareEntry))
+			assert.NoError(t, client.AddFieldsToHash("REENTERED", bareEntry))
+			assert.NoError(t, sessionStore.OpenSession(models.Session{VehiclePlate: "REENTERED", EntryDateTime: exitTime}))
+			assert.NoError(t, client.AddFieldsToHash("overstay_alerted", map[string]string{"XYZ789|" + entryTime.Format(time.RFC3339): "1"}))
+
+			moved, err := MigrateBareKeys(client, strings.ToUpper)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, moved)
+			open, err := sessionStore.ListOpen()
+			assert.NoError(t, err)
+			assert.Equal(t, []models.Session{
+				{VehiclePlate: "ABC-123", RawEntryPlate: "abc-123", EntryDateTime: entryTime},
+				{VehiclePlate: "REENTERED", EntryDateTime: exitTime},
+			}, open)
+			keys, err := client.ScanHashKeys("*")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"{ABC-123}", "{REENTERED}", "overstay_alerted"}, keys)
+		})
+	}
+}
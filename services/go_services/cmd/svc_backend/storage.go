@@ -0,0 +1,137 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/invoicing"
+	"go_services/cmd/svc_backend/processors"
+	"go_services/cmd/svc_backend/registry"
+	"go_services/cmd/svc_backend/reports"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/logger"
+	"go_services/pkg/memstore"
+	"go_services/pkg/redis"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	redisBackend  = "redis"
+	memoryBackend = "memory"
+
+	hashSessionBackend = "hash"
+	sqlSessionBackend  = "sql"
+)
+
+// sqlDrivers maps the configured SQL driver to the name registered with database/sql.
+var sqlDrivers = map[string]string{
+	"sqlite":   "sqlite",
+	"postgres": "pgx",
+}
+
+// storage is the set of data operations the backend needs; it is provided by Redis or, for local
+// runs, by an in-memory store.
+type storage interface {
+	sessions.HashStore
+	processors.ValidationStore
+	reports.ListStore
+	registry.HashReader
+	invoicing.NumberStore
+}
+
+// openStorage connects to the configured storage backend and returns it with a function that
+// releases it.
+func openStorage(cfg *config.Config) (storage, func(), error) {
+	switch cfg.StoreBackend {
+	case redisBackend:
+		redisOpts, err := redis.NewOptions(cfg.RedisSettings())
+		if err != nil {
+			return nil, nil, err
+		}
+		redisClient, err := redis.Connect(redisOpts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return redisClient, func() { redisClient.Client.Close() }, nil
+	case memoryBackend:
+		return openMemoryStore(cfg)
+	default:
+		return nil, nil, fmt.Errorf("unknown store backend %q", cfg.StoreBackend)
+	}
+}
+
+// openMemoryStore creates the in-memory store, restored from and periodically saved to the snapshot
+// file when one is configured. The final snapshot is also written when the process is terminated.
+func openMemoryStore(cfg *config.Config) (storage, func(), error) {
+	if cfg.MemorySnapshotFile == "" {
+		logger.Log.Warn().Msg("Using in-memory storage without snapshots; data is lost on exit")
+		return memstore.New(), func() {}, nil
+	}
+
+	store, err := memstore.Load(cfg.MemorySnapshotFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Log.Info().Msgf("Using in-memory storage with snapshots in %s", cfg.MemorySnapshotFile)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		store.RunSnapshots(cfg.MemorySnapshotFile, cfg.MemorySnapshotInterval, stop)
+		close(done)
+	}()
+	closeStore := func() {
+		select {
+		case <-stop:
+		default:
+			close(stop)
+			<-done
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Log.Info().Msgf("Received %v, saving snapshot", sig)
+		closeStore()
+		os.Exit(0)
+	}()
+
+	return store, closeStore, nil
+}
+
+// openSessionStore returns the configured session store: the hashes of the storage backend, or a
+// migrated SQL database. The returned function releases it.
+func openSessionStore(cfg *config.Config, store storage) (processors.SessionStore, func(), error) {
+	switch cfg.SessionBackend {
+	case hashSessionBackend:
+		return &sessions.RedisStore{Client: store}, func() {}, nil
+	case sqlSessionBackend:
+		driver, ok := sqlDrivers[cfg.SQLDriver]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown SQL driver %q", cfg.SQLDriver)
+		}
+		db, err := sql.Open(driver, cfg.SQLDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening SQL database: %v", err)
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("error connecting to SQL database: %v", err)
+		}
+		if err := sessions.Migrate(db, cfg.SQLDriver); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		logger.Log.Info().Msgf("Keeping sessions in %s database", cfg.SQLDriver)
+		return &sessions.SQLStore{DB: db, Dialect: cfg.SQLDriver}, func() { db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown session backend %q", cfg.SessionBackend)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_services/cmd/svc_backend/config"
+	"go_services/pkg/rabbitmq"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// fileOffsetStore keeps the stream offsets in a JSON file, outside the storage backend that a replay
+// rebuilds. The file is replaced atomically on each save.
+type fileOffsetStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileOffsetStore) load() (map[string]int64, error) {
+	offsets := map[string]int64{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return offsets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, fmt.Errorf("invalid stream offset file %s: %v", s.path, err)
+	}
+	return offsets, nil
+}
+
+func (s *fileOffsetStore) LoadOffset(stream string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offsets, err := s.load()
+	if err != nil {
+		return 0, false, err
+	}
+	offset, ok := offsets[stream]
+	return offset, ok, nil
+}
+
+func (s *fileOffsetStore) SaveOffset(stream string, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offsets, err := s.load()
+	if err != nil {
+		return err
+	}
+	offsets[stream] = offset
+	data, err := json.Marshal(offsets)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// consumeEventStream consumes the entries and exits of the event stream, from the configured replay
+// time or after the stored offset. Events processed before are handed to the replay processors.
+func consumeEventStream(cfg *config.Config, rabbitMQClient *rabbitmq.RabbitMQClient, svc *service, offsets rabbitmq.OffsetStore) error {
+	var replayFrom time.Time
+	if cfg.StreamReplayFrom != "" {
+		var err error
+		if replayFrom, err = time.Parse(time.RFC3339, cfg.StreamReplayFrom); err != nil {
+			return err
+		}
+	}
+	return rabbitMQClient.ConsumeStream(cfg.EventQueueName, offsets, replayFrom, streamHandler(svc))
+}
+
+// streamHandler dispatches stream events by type to the service's live or replay processors.
+func streamHandler(svc *service) rabbitmq.StreamHandler {
+	live := map[string]rabbitmq.Client{rabbitmq.EntryEvent: &svc.entry, rabbitmq.ExitEvent: &svc.exit}
+	replay := map[string]rabbitmq.Client{rabbitmq.EntryEvent: &svc.replayEntry, rabbitmq.ExitEvent: &svc.replayExit}
+	return func(msg amqp091.Delivery, replayed bool) error {
+		if replayed {
+			return rabbitmq.Dispatch(replay, msg)
+		}
+		return rabbitmq.Dispatch(live, msg)
+	}
+}
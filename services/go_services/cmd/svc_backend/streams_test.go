@@ -0,0 +1,82 @@
+package main
+
+import (
+	"go_services/cmd/svc_backend/config"
+	"go_services/cmd/svc_backend/processors"
+	"go_services/cmd/svc_backend/sessions"
+	"go_services/pkg/memstore"
+	"go_services/pkg/rabbitmq"
+	"path/filepath"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingProcessor struct {
+	name     string
+	received *[]string
+}
+
+func (p recordingProcessor) ProcessMessage(msg []byte) error {
+	*p.received = append(*p.received, p.name+" "+string(msg))
+	return nil
+}
+
+func TestFileOffsetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream_offsets.json")
+	offsets := &fileOffsetStore{path: path}
+
+	_, ok, err := offsets.LoadOffset("vehicle_events")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, offsets.SaveOffset("vehicle_events", 42))
+	offset, ok, err := offsets.LoadOffset("vehicle_events")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), offset)
+
+	offset, ok, err = (&fileOffsetStore{path: path}).LoadOffset("vehicle_events")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), offset)
+}
+
+func TestStreamHandler(t *testing.T) {
+	var received []string
+	svc := &service{}
+	svc.entry.set(recordingProcessor{"entry", &received})
+	svc.exit.set(recordingProcessor{"exit", &received})
+	svc.replayEntry.set(recordingProcessor{"replayed entry", &received})
+	svc.replayExit.set(recordingProcessor{"replayed exit", &received})
+
+	handle := streamHandler(svc)
+	assert.NoError(t, handle(amqp091.Delivery{Type: rabbitmq.EntryEvent, Body: []byte("1")}, true))
+	assert.NoError(t, handle(amqp091.Delivery{Type: rabbitmq.ExitEvent, Body: []byte("2")}, true))
+	assert.NoError(t, handle(amqp091.Delivery{Type: rabbitmq.ExitEvent, Body: []byte("3")}, false))
+	assert.Error(t, handle(amqp091.Delivery{Type: "validation", Body: []byte("4")}, false))
+
+	assert.Equal(t, []string{"replayed entry 1", "replayed exit 2", "exit 3"}, received)
+}
+
+func TestBuildPipeline_ReplaySummaries(t *testing.T) {
+	cfg := &config.Config{DuplicateEntryPolicy: "keep_latest", APIURL: "http://localhost/parkinglog"}
+	p, err := buildPipeline(cfg, nil, memstore.New(), sessions.NewMemoryStore())
+	assert.NoError(t, err)
+	assert.Equal(t, processors.MultiPoster{}, p.replayExit.SummaryPoster)
+	assert.Equal(t, processors.MultiPoster{}, p.replayEntry.SummaryPoster)
+	assert.NotEmpty(t, p.exit.SummaryPoster)
+	assert.Equal(t, discardSink{}, p.replayEntry.AlertPublisher)
+	assert.Equal(t, discardSink{}, p.replayEntry.ReviewPublisher)
+	assert.Equal(t, discardSink{}, p.replayExit.ReviewPublisher)
+	assert.Equal(t, discardSink{}, p.replayExit.OrphanRecorder)
+	assert.IsType(t, &queueAlertPublisher{}, p.entry.AlertPublisher)
+
+	cfg.StreamReplayPostSummaries = true
+	p, err = buildPipeline(cfg, nil, memstore.New(), sessions.NewMemoryStore())
+	assert.NoError(t, err)
+	assert.Equal(t, p.exit.SummaryPoster, p.replayExit.SummaryPoster)
+	assert.Equal(t, p.entry.SummaryPoster, p.replayEntry.SummaryPoster)
+	assert.Equal(t, discardSink{}, p.replayExit.OrphanRecorder)
+}
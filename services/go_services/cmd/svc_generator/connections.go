@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"go_services/cmd/svc_generator/config"
+	"go_services/pkg/kafka"
+	"go_services/pkg/logger"
+	"go_services/pkg/rabbitmq"
+	"go_services/pkg/tlsconfig"
+	"go_services/pkg/transport"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitMQTopology returns the topic exchange the events are published to, or without an exchange the
+// queue, with the configured queue arguments and dead letter exchange.
+func rabbitMQTopology(cfg *config.Config) rabbitmq.Topology {
+	options := rabbitmq.QueueOptions{
+		Type:               cfg.RabbitMQQueueType,
+		MessageTTL:         cfg.RabbitMQMessageTTL,
+		MaxLength:          cfg.RabbitMQMaxLength,
+		DeadLetterExchange: cfg.RabbitMQDeadLetterExchange,
+	}
+	if cfg.Exchange == "" {
+		return rabbitmq.NewTopology([]string{cfg.QueueName}, options, cfg.RabbitMQDeadLetterQueue)
+	}
+	// the consumers declare and bind their queues
+	topology := rabbitmq.NewTopology(nil, options, cfg.RabbitMQDeadLetterQueue)
+	topology.Exchanges = append(topology.Exchanges, rabbitmq.Exchange{Name: cfg.Exchange, Kind: amqp091.ExchangeTopic})
+	return topology
+}
+
+// openPublisher connects to the configured message broker and returns the publisher with a function
+// that releases it. RabbitMQ exchanges or queues are declared first when enabled.
+func openPublisher(cfg *config.Config) (transport.Publisher, func(), error) {
+	if cfg.Transport == config.KafkaTransport {
+		publisher := kafka.NewPublisher(strings.Split(cfg.KafkaBrokers, ","))
+		logger.Log.Info().Msgf("Publishing to Kafka brokers %s", cfg.KafkaBrokers)
+		return publisher, publisher.Close, nil
+	}
+
+	rabbitMQTLSConfig, err := tlsconfig.LoadConfigured(cfg.RabbitMQTLSFiles())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading RabbitMQ TLS configuration: %v", err)
+	}
+	rabbitMQClient, err := rabbitmq.Connect(cfg.RabbitMQURL, rabbitMQTLSConfig, rabbitmq.DefaultConnectRetry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.RabbitMQDeclareTopology {
+		if err := rabbitMQClient.DeclareTopology(rabbitMQTopology(cfg)); err != nil {
+			rabbitMQClient.Close()
+			return nil, nil, err
+		}
+	}
+	publisher := rabbitMQClient.NewPublisher(rabbitmq.DefaultPublisherChannels)
+	closePublisher := func() {
+		publisher.Close()
+		rabbitMQClient.Close()
+	}
+	return &rabbitmq.ExchangePublisher{Publisher: publisher, Exchange: cfg.Exchange}, closePublisher, nil
+}
@@ -2,22 +2,55 @@ package event
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"time"
 )
 
+// Camera describes the simulated ANPR camera; with an empty ID events carry no camera details.
+type Camera struct {
+	ID              string
+	SnapshotBaseURI string
+}
+
+// CameraRead holds the optional details a camera reports with a plate read.
+type CameraRead struct {
+	Confidence  float64 `json:"confidence,omitempty"`
+	CameraID    string  `json:"camera_id,omitempty"`
+	SnapshotURI string  `json:"snapshot_uri,omitempty"`
+}
+
+// read simulates a plate read with a confidence between 0.5 and 1.
+func (c Camera) read(eventID string) CameraRead {
+	if c.ID == "" {
+		return CameraRead{}
+	}
+
+	read := CameraRead{
+		Confidence: math.Round((0.5+rand.Float64()*0.5)*100) / 100,
+		CameraID:   c.ID,
+	}
+	if c.SnapshotBaseURI != "" {
+		read.SnapshotURI = fmt.Sprintf("%s/%s/%s.jpg", c.SnapshotBaseURI, c.ID, eventID)
+	}
+	return read
+}
+
 type EntryEventPayload struct {
 	ID            string    `json:"id"`
 	VehiclePlate  string    `json:"vehicle_plate"`
 	EntryDateTime time.Time `json:"entry_date_time"`
+	CameraRead
 }
 
-func GenerateEntryEvent() EntryEventPayload {
+func GenerateEntryEvent(camera Camera) EntryEventPayload {
 
+	id := fmt.Sprintf("%d", rand.Int())
 	return EntryEventPayload{
-		ID:            fmt.Sprintf("%d", rand.Int()),
+		ID:            id,
 		VehiclePlate:  fmt.Sprintf("plate-%d", rand.Intn(1000)),
 		EntryDateTime: time.Now().UTC(),
+		CameraRead:    camera.read(id),
 	}
 }
 
@@ -25,13 +58,16 @@ type ExitEventPayload struct {
 	ID           string    `json:"id"`
 	VehiclePlate string    `json:"vehicle_plate"`
 	ExitDateTime time.Time `json:"exit_date_time"`
+	CameraRead
 }
 
-func GenerateExitEvent() ExitEventPayload {
+func GenerateExitEvent(camera Camera) ExitEventPayload {
 
+	id := fmt.Sprintf("%d", rand.Int())
 	return ExitEventPayload{
-		ID:           fmt.Sprintf("%d", rand.Int()),
+		ID:           id,
 		VehiclePlate: fmt.Sprintf("plate-%d", rand.Intn(1000)),
 		ExitDateTime: time.Now().UTC(),
+		CameraRead:   camera.read(id),
 	}
 }
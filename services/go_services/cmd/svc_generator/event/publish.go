@@ -0,0 +1,18 @@
+package event
+
+import (
+	"go_services/cmd/svc_generator/config"
+	"go_services/pkg/rabbitmq"
+	"go_services/pkg/transport"
+)
+
+// Publish publishes the event keyed by its plate: to the exchange with the facility's routing key for
+// the event type, to the queue without an exchange, or to the Kafka topic named by the queue. The
+// event type is set as message type for shared queues and topics.
+func Publish(publisher transport.Publisher, cfg *config.Config, eventType, vehiclePlate string, eventPayload any) error {
+	destination := cfg.QueueName
+	if cfg.Transport != config.KafkaTransport && cfg.Exchange != "" {
+		destination = rabbitmq.EventRoutingKey(cfg.Facility, eventType)
+	}
+	return publisher.Publish(destination, vehiclePlate, eventType, eventPayload)
+}
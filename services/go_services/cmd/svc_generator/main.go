@@ -1,36 +1,68 @@
 package main
 
 import (
+	"fmt"
 	"go_services/cmd/svc_generator/config"
 	"go_services/cmd/svc_generator/event"
+	pkgconfig "go_services/pkg/config"
 	"go_services/pkg/logger"
-	"go_services/pkg/rabbitmq"
 	"go_services/pkg/redis"
+	"go_services/pkg/transport"
 	"math/rand"
+	"net/http"
+	"os"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
 	redisSetName = "parked_vehicles"
 )
 
+// startMetricsServer starts the Prometheus metrics HTTP server with the publish metrics
+func startMetricsServer() {
+	go func() {
+		http.Handle("/metrics", promhttp.Handler())
+		logger.Log.Debug().Msg("Starting Prometheus metrics server on :2112/metrics")
+		if err := http.ListenAndServe(":2112", nil); err != nil {
+			logger.Log.Fatal().Err(err).Msgf("Error starting Prometheus server: %v", err)
+		}
+	}()
+}
+
 func main() {
-	cfg := config.LoadConfig()
+	cfg, loaded, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "svc_generator: invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if loaded.PrintConfig {
+		if err := pkgconfig.Print(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "svc_generator: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	logger.InitLogger(cfg.LogLevel)
 
-	// Connect to RabbitMQ
-	// Create RabbitMQ client
-	rabbitMQClient, err := rabbitmq.GetRabbitMQClient(cfg.RabbitMQURL)
+	// Connect to the message broker
+	publisher, closePublisher, err := openPublisher(cfg)
 	if err != nil {
-		logger.Log.Fatal().Err(err).Msg("Failed to initialize RabbitMQ client")
+		logger.Log.Fatal().Err(err).Msg("Failed to initialize the event publisher")
 	}
-	defer rabbitMQClient.Close()
+	defer closePublisher()
+	startMetricsServer()
 
 	//ctx := context.Background()
 
 	logger.Log.Info().Msg("Successfully connected to Redis")
 
-	redisClient, err := redis.GetRedisClient(cfg.RedisAddress, cfg.RedisPassword, cfg.RedisDB)
+	redisOpts, err := redis.NewOptions(cfg.RedisSettings())
+	if err != nil {
+		logger.Log.Fatal().Err(err).Msg("Error connecting to Redis:")
+	}
+	redisClient, err := redis.Connect(redisOpts)
 	if err != nil {
 		logger.Log.Fatal().Err(err).Msg("Error connecting to Redis:")
 	}
@@ -41,11 +73,12 @@ func main() {
 	}()
 
 	logger.Log.Info().Msg(cfg.GeneratorMode)
+	camera := event.Camera{ID: cfg.CameraID, SnapshotBaseURI: cfg.SnapshotBaseURI}
 	if cfg.GeneratorMode == "entry" {
 
 		for {
-			eventPayload := event.GenerateEntryEvent()
-			err := rabbitmq.PublishEvent(rabbitMQClient.Connection, cfg.QueueName, eventPayload)
+			eventPayload := event.GenerateEntryEvent(camera)
+			err := event.Publish(publisher, cfg, transport.EntryEvent, eventPayload.VehiclePlate, eventPayload)
 			if err != nil {
 				logger.Log.Error().Err(err).Msg("Failed to publish event")
 			}
@@ -72,7 +105,7 @@ func main() {
 				logger.Log.Fatal().Err(err).Msg("Error checking set")
 			}
 
-			eventPayload := event.GenerateExitEvent()
+			eventPayload := event.GenerateExitEvent(camera)
 
 			randomPercent := rand.Intn(100) + 1
 			logger.Log.Debug().Msgf("random number %d", randomPercent)
@@ -86,7 +119,7 @@ func main() {
 				if err == nil {
 
 					eventPayload.VehiclePlate = parkedVehiclePlate
-					err = rabbitmq.PublishEvent(rabbitMQClient.Connection, cfg.QueueName, eventPayload)
+					err = event.Publish(publisher, cfg, transport.ExitEvent, eventPayload.VehiclePlate, eventPayload)
 					if err != nil {
 						logger.Log.Error().Err(err).Msg("Failed to publish event")
 					}
@@ -100,7 +133,7 @@ func main() {
 				}
 			} else {
 
-				err = rabbitmq.PublishEvent(rabbitMQClient.Connection, cfg.QueueName, eventPayload)
+				err = event.Publish(publisher, cfg, transport.ExitEvent, eventPayload.VehiclePlate, eventPayload)
 				if err != nil {
 					logger.Log.Error().Err(err).Msg("Failed to publish event")
 				}
@@ -0,0 +1,367 @@
+// Package config loads service configurations in layers: the defaults in the struct tags, an optional
+// YAML or TOML file, environment variables and command-line flags, each overriding the one before.
+//
+// The fields of a configuration struct are described by tags:
+//
+//	key       name in config files; the flag name is the key with dashes, e.g. --redis-db
+//	env       environment variable
+//	default   value used when no layer sets the field
+//	required  "true" when the value must not be empty
+//	min, max  inclusive range of numeric and duration fields
+//	oneof     space separated list of the allowed values
+//	secret    "true" to redact the value when printed, "url" to redact only the password of a URL.
+//	          Secrets may also be read from the file named by the env var with a _FILE suffix.
+//	reload    "true" when a running service can apply a changed value; see KeepFixed
+//
+// Supported field types are string, bool, int, int64, float64 and time.Duration.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that selects the config file when --config is not given.
+const FileEnv = "CONFIG_FILE"
+
+// Validator is implemented by configurations with rules across fields. provided reports whether a
+// key was set by a file, env var or flag rather than defaulted.
+type Validator interface {
+	Validate(provided func(key string) bool) error
+}
+
+// ArgsValidator is implemented by configurations whose rules depend on the positional args, e.g. a
+// subcommand that needs fewer settings. It is used instead of Validator.
+type ArgsValidator interface {
+	ValidateArgs(args []string, provided func(key string) bool) error
+}
+
+// Loaded describes how a configuration was loaded.
+type Loaded struct {
+	File        string   // config file, if any
+	PrintConfig bool     // --print-config was given
+	Args        []string // arguments after the flags, e.g. a subcommand
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a tagged field of a configuration struct.
+type field struct {
+	value    reflect.Value
+	key      string
+	env      string
+	def      string
+	required bool
+	min, max string
+	oneof    []string
+	secret   string
+	reload   bool
+}
+
+// Load fills cfg, a pointer to a tagged struct, from its defaults, the config file, the environment and
+// the command-line args, and validates it. name is the program name used in flag errors.
+func Load(cfg any, name string, args []string) (*Loaded, error) {
+	fields, err := describe(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		if f.def != "" {
+			if err := set(f, f.def); err != nil {
+				return nil, fmt.Errorf("invalid default: %w", err)
+			}
+		}
+	}
+
+	loaded := &Loaded{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&loaded.File, "config", os.Getenv(FileEnv), "YAML or TOML config file")
+	flags.BoolVar(&loaded.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	flagValues := make([]*flagValue, len(fields))
+	for i, f := range fields {
+		flagValues[i] = &flagValue{isBool: f.value.Kind() == reflect.Bool}
+		flags.Var(flagValues[i], strings.ReplaceAll(f.key, "_", "-"), "sets "+f.key)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid flags: %v", err)
+	}
+	if args := flags.Args(); len(args) > 0 {
+		loaded.Args = args
+	}
+
+	provided := make(map[string]bool)
+	var errs []error
+	if loaded.File != "" {
+		values, err := readFile(loaded.File)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			value, ok := values[f.key]
+			if !ok {
+				continue
+			}
+			delete(values, f.key)
+			provided[f.key] = true
+			errs = append(errs, set(f, value))
+		}
+		for key := range values {
+			errs = append(errs, fmt.Errorf("unknown key %q in %s", key, loaded.File))
+		}
+	}
+
+	for _, f := range fields {
+		value, ok, err := lookupEnv(f)
+		if ok {
+			provided[f.key] = true
+			err = set(f, value)
+		}
+		errs = append(errs, err)
+	}
+
+	for i, f := range fields {
+		if flagValues[i].set {
+			provided[f.key] = true
+			errs = append(errs, set(f, flagValues[i].value))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if err := validate(cfg, fields, provided, loaded.Args); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// describe returns the tagged fields of cfg.
+func describe(cfg any) ([]field, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a pointer to a struct, got %T", cfg)
+	}
+	v = v.Elem()
+
+	var fields []field
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		key := structField.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		f := field{
+			value:    v.Field(i),
+			key:      key,
+			env:      structField.Tag.Get("env"),
+			def:      structField.Tag.Get("default"),
+			required: structField.Tag.Get("required") == "true",
+			min:      structField.Tag.Get("min"),
+			max:      structField.Tag.Get("max"),
+			secret:   structField.Tag.Get("secret"),
+			reload:   structField.Tag.Get("reload") == "true",
+		}
+		if oneof := structField.Tag.Get("oneof"); oneof != "" {
+			f.oneof = strings.Fields(oneof)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// set parses raw into the field.
+func set(f field, raw string) error {
+	v := f.value
+	var err error
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(raw); err == nil {
+			v.SetInt(int64(d))
+		}
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			v.SetBool(b)
+		}
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(raw, 10, 64); err == nil {
+			v.SetInt(i)
+		}
+	case v.Kind() == reflect.Float64:
+		var x float64
+		if x, err = strconv.ParseFloat(raw, 64); err == nil {
+			v.SetFloat(x)
+		}
+	default:
+		return fmt.Errorf("unsupported type %v of %s", v.Type(), f.key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", raw, f.key, err)
+	}
+	return nil
+}
+
+// lookupEnv returns the value of the field's env var, or for secrets the content of the file named by
+// its _FILE variant.
+func lookupEnv(f field) (string, bool, error) {
+	if f.env == "" {
+		return "", false, nil
+	}
+	value, envSet := os.LookupEnv(f.env)
+	if f.secret == "" {
+		return value, envSet, nil
+	}
+
+	file, fileSet := os.LookupEnv(f.env + "_FILE")
+	switch {
+	case envSet && fileSet:
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", f.env, f.env)
+	case fileSet:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("error reading %s_FILE: %v", f.env, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	default:
+		return value, envSet, nil
+	}
+}
+
+// readFile reads the flat key/value pairs of a YAML or TOML config file.
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch value.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("config file %s: %s must be a single value", path, key)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// validate checks the tag rules of the fields and the rules of an ArgsValidator or Validator.
+func validate(cfg any, fields []field, provided map[string]bool, args []string) error {
+	var errs []error
+	for _, f := range fields {
+		errs = append(errs, validateField(f))
+	}
+	isProvided := func(key string) bool { return provided[key] }
+	if validator, ok := cfg.(ArgsValidator); ok {
+		errs = append(errs, validator.ValidateArgs(args, isProvided))
+	} else if validator, ok := cfg.(Validator); ok {
+		errs = append(errs, validator.Validate(isProvided))
+	}
+	return errors.Join(errs...)
+}
+
+func validateField(f field) error {
+	v := f.value
+	if f.required && v.IsZero() {
+		return fmt.Errorf("%s is required (%s)", f.key, f.env)
+	}
+	if len(f.oneof) > 0 && !contains(f.oneof, v.String()) {
+		return fmt.Errorf("%s must be one of %s, got %q", f.key, strings.Join(f.oneof, ", "), v.String())
+	}
+	for _, bound := range []struct {
+		limit   string
+		outside func(value, limit float64) bool
+		message string
+	}{
+		{f.min, func(value, limit float64) bool { return value < limit }, "at least"},
+		{f.max, func(value, limit float64) bool { return value > limit }, "at most"},
+	} {
+		if bound.limit == "" {
+			continue
+		}
+		value, limit, err := number(v, bound.limit)
+		if err != nil {
+			return fmt.Errorf("invalid range of %s: %v", f.key, err)
+		}
+		if bound.outside(value, limit) {
+			return fmt.Errorf("%s must be %s %s, got %s", f.key, bound.message, bound.limit, format(f))
+		}
+	}
+	return nil
+}
+
+// number returns the field value and a range limit as comparable numbers.
+func number(v reflect.Value, limit string) (float64, float64, error) {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(limit)
+		return float64(v.Int()), float64(d), err
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		l, err := strconv.ParseFloat(limit, 64)
+		return float64(v.Int()), l, err
+	case v.Kind() == reflect.Float64:
+		l, err := strconv.ParseFloat(limit, 64)
+		return v.Float(), l, err
+	default:
+		return 0, 0, fmt.Errorf("type %v has no range", v.Type())
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValue keeps the raw value of a flag so that it can be applied after the file and env layers.
+type flagValue struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string { return f.value }
+
+func (f *flagValue) Set(value string) error {
+	f.value = value
+	f.set = true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+// Entry is a configuration value prepared for printing.
+type Entry struct {
+	Key   string
+	Value any
+}
+
+// Entries returns the values of cfg in field order, with secrets redacted.
+func Entries(cfg any) ([]Entry, error) {
+	fields, err := describe(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(fields))
+	for _, f := range fields {
+		var value any = f.value.Interface()
+		switch f.secret {
+		case "":
+			if f.value.Type() == durationType {
+				value = format(f)
+			}
+		case "url":
+			value = RedactURL(f.value.String())
+		default:
+			value = Redact(f.value.String())
+		}
+		entries = append(entries, Entry{Key: f.key, Value: value})
+	}
+	return entries, nil
+}
+
+// Print writes the redacted configuration to w as YAML, in a form that can be read back as a config file.
+func Print(w io.Writer, cfg any) error {
+	entries, err := Entries(cfg)
+	if err != nil {
+		return err
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, entry := range entries {
+		var value yaml.Node
+		if err := value.Encode(entry.Value); err != nil {
+			return err
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: entry.Key}, &value)
+	}
+	return yaml.NewEncoder(w).Encode(doc)
+}
+
+// Redact hides a secret for logging.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// RedactURL hides the password of a URL or connection string for logging, keeping the rest readable.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Redact(raw)
+	}
+	if u.User != nil {
+		return u.Redacted()
+	}
+	if strings.Contains(strings.ToLower(raw), "password") {
+		// e.g. a "host=db password=secret" connection string
+		return redacted
+	}
+	return raw
+}
+
+// format returns the field value as it would be written in a config file.
+func format(f field) string {
+	return fmt.Sprint(f.value.Interface())
+}
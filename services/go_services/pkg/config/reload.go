@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KeepFixed prepares next, a reloaded configuration, to replace current: every field not tagged
+// reload:"true" is set back to its current value, as it only takes effect on restart. It returns the
+// keys of such fields whose value was changed in next.
+func KeepFixed(next any, current any) ([]string, error) {
+	if reflect.TypeOf(next) != reflect.TypeOf(current) {
+		return nil, fmt.Errorf("cannot reload %T with %T", current, next)
+	}
+	nextFields, err := describe(next)
+	if err != nil {
+		return nil, err
+	}
+	currentFields, err := describe(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixed []string
+	for i, f := range nextFields {
+		old := currentFields[i].value
+		if f.reload || reflect.DeepEqual(f.value.Interface(), old.Interface()) {
+			continue
+		}
+		fixed = append(fixed, f.key)
+		f.value.Set(old)
+	}
+	return fixed, nil
+}
@@ -0,0 +1,276 @@
+// Package kafka publishes and consumes events on Kafka topics through the transport-neutral interfaces.
+// Events are partitioned by their key, the vehicle plate, so that the events of a plate stay in order,
+// and consumed offsets are committed once an event was processed or moved to the dead-letter topic.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go_services/pkg/logger"
+	"go_services/pkg/retry"
+	"go_services/pkg/transport"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	TypeHeader        = "type"                // header carrying the event type
+	ErrorHeader       = "error"               // header of dead-lettered events carrying the last processing error
+	SourceTopicHeader = "source_topic"        // header of dead-lettered events carrying the topic they were consumed from
+	writeTimeout      = 10 * time.Second      // maximum wait for the brokers to acknowledge an event
+	batchTimeout      = 10 * time.Millisecond // how long a write waits for more events to batch
+	fetchRetryWait    = 3 * time.Second       // delay after a failed fetch
+)
+
+// DefaultProcessRetry is the retry policy of failing events until one is set: after its attempts an
+// event is dead-lettered.
+var DefaultProcessRetry = retry.Policy{Attempts: 5, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+var (
+	_ transport.Publisher = (*Publisher)(nil)
+	_ transport.Consumer  = (*Consumer)(nil)
+)
+
+// messageWriter is the part of *kafkago.Writer used to publish.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// messageReader is the part of *kafkago.Reader used to consume.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Publisher publishes events to topics, partitioned by the hash of their key.
+type Publisher struct {
+	writer messageWriter
+}
+
+// NewPublisher creates a publisher on the brokers that waits for all in-sync replicas to acknowledge
+// each event.
+func NewPublisher(brokers []string) *Publisher {
+	return &Publisher{writer: &kafkago.Writer{
+		Addr:                   kafkago.TCP(brokers...),
+		Balancer:               &kafkago.Hash{},
+		RequiredAcks:           kafkago.RequireAll,
+		BatchTimeout:           batchTimeout,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+// Publish publishes the event as JSON to the topic with the key and the event type header.
+func (p *Publisher) Publish(topic, key, eventType string, eventPayload any) error {
+	body, err := json.Marshal(eventPayload)
+	if err != nil {
+		logger.Log.Error().Err(err).Msgf("JSON conversion error in %v...", eventPayload)
+		return err
+	}
+
+	msg := kafkago.Message{Topic: topic, Key: []byte(key), Value: body}
+	if eventType != "" {
+		msg.Headers = []kafkago.Header{{Key: TypeHeader, Value: []byte(eventType)}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("error publishing to topic %s: %w", topic, err)
+	}
+
+	logger.Log.Info().Msgf("Published event: %s", body)
+	return nil
+}
+
+// Close flushes and closes the publisher.
+func (p *Publisher) Close() {
+	if err := p.writer.Close(); err != nil {
+		logger.Log.Error().Err(err).Msg("Failed to close Kafka publisher")
+	}
+}
+
+// Consumer consumes topics as member of a consumer group.
+type Consumer struct {
+	newReader       func(topic string) messageReader
+	deadLetters     messageWriter // nil without a dead-letter topic
+	deadLetterTopic string
+	ctx             context.Context
+	cancel          context.CancelFunc
+
+	mu      sync.Mutex
+	retry   retry.Policy
+	readers []messageReader
+	done    sync.WaitGroup
+}
+
+// NewConsumer creates a consumer on the brokers in the consumer group; consumption resumes after the
+// group's committed offsets. Failing events are retried by the policy (DefaultProcessRetry when unset)
+// and then published to deadLetterTopic; without it they are retried until they succeed.
+func NewConsumer(brokers []string, groupID string, deadLetterTopic string, policy retry.Policy) *Consumer {
+	consumer := newConsumer(func(topic string) messageReader {
+		return kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers: brokers,
+			GroupID: groupID,
+			Topic:   topic,
+		})
+	})
+	if deadLetterTopic != "" {
+		consumer.deadLetters = NewPublisher(brokers).writer
+		consumer.deadLetterTopic = deadLetterTopic
+	}
+	consumer.SetRetryPolicy(policy)
+	return consumer
+}
+
+func newConsumer(newReader func(topic string) messageReader) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{newReader: newReader, retry: DefaultProcessRetry, ctx: ctx, cancel: cancel}
+}
+
+// SetRetryPolicy replaces the retry policy of failing events, e.g. on a configuration reload; events
+// already being retried keep the policy they started with.
+func (c *Consumer) SetRetryPolicy(policy retry.Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retry = policy.Or(DefaultProcessRetry)
+}
+
+// Consume consumes the topic and hands the events to the handler.
+func (c *Consumer) Consume(topic string, handler transport.Handler) error {
+	return c.consume(topic, func(msg kafkago.Message) error {
+		return handler.ProcessMessage(msg.Value)
+	})
+}
+
+// ConsumeTyped consumes the topic and hands each event to the handler of its type header.
+func (c *Consumer) ConsumeTyped(topic string, handlers map[string]transport.Handler) error {
+	return c.consume(topic, func(msg kafkago.Message) error {
+		return transport.Dispatch(handlers, MessageType(msg), msg.Value)
+	})
+}
+
+func (c *Consumer) consume(topic string, handle func(msg kafkago.Message) error) error {
+	if c.ctx.Err() != nil {
+		return errors.New("consumer is closed")
+	}
+	reader := c.newReader(topic)
+	c.mu.Lock()
+	c.readers = append(c.readers, reader)
+	c.mu.Unlock()
+
+	c.done.Add(1)
+	go func() {
+		defer c.done.Done()
+		c.run(topic, reader, handle)
+	}()
+	return nil
+}
+
+// run processes the events of a reader until the consumer is closed. The offset of an event is
+// committed once it was processed or dead-lettered, so that events are redelivered after a crash or a
+// failure rather than lost.
+func (c *Consumer) run(topic string, reader messageReader, handle func(msg kafkago.Message) error) {
+	for {
+		msg, err := reader.FetchMessage(c.ctx)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			logger.Log.Error().Err(err).Msgf("Failed to fetch from topic %s, retrying in %v...", topic, fetchRetryWait)
+			select {
+			case <-time.After(fetchRetryWait):
+				continue
+			case <-c.ctx.Done():
+				return
+			}
+		}
+
+		if !c.process(topic, msg, handle) {
+			return
+		}
+		if err := reader.CommitMessages(c.ctx, msg); err != nil && c.ctx.Err() == nil {
+			logger.Log.Error().Err(err).Msgf("Failed to commit offset %d of topic %s partition %d", msg.Offset, topic, msg.Partition)
+		}
+	}
+}
+
+// process hands the event to the handler, retrying with the growing delay of the retry policy while it
+// fails. After the policy's attempts the event is published to the dead-letter topic, if any, so that
+// the partition moves on. It reports false when the consumer was closed first; the event is then left
+// uncommitted.
+func (c *Consumer) process(topic string, msg kafkago.Message, handle func(msg kafkago.Message) error) bool {
+	c.mu.Lock()
+	policy := c.retry
+	c.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		err := handle(msg)
+		if err == nil {
+			return true
+		}
+		logger.Log.Error().Err(err).Msgf("Failed to process offset %d of topic %s partition %d (attempt %d)", msg.Offset, topic, msg.Partition, attempt)
+
+		if attempt >= policy.Attempts && c.deadLetters != nil {
+			dlErr := c.deadLetter(topic, msg, err)
+			if dlErr == nil {
+				logger.Log.Warn().Msgf("Moved offset %d of topic %s partition %d to dead-letter topic %s", msg.Offset, topic, msg.Partition, c.deadLetterTopic)
+				return true
+			}
+			logger.Log.Error().Err(dlErr).Msgf("Failed to publish to dead-letter topic %s", c.deadLetterTopic)
+		}
+
+		select {
+		case <-time.After(policy.Backoff(attempt)):
+		case <-c.ctx.Done():
+			return false
+		}
+	}
+}
+
+// deadLetter publishes the event unchanged to the dead-letter topic, with the error and the topic it
+// was consumed from as headers.
+func (c *Consumer) deadLetter(topic string, msg kafkago.Message, cause error) error {
+	headers := append([]kafkago.Header(nil), msg.Headers...)
+	headers = append(headers,
+		kafkago.Header{Key: ErrorHeader, Value: []byte(cause.Error())},
+		kafkago.Header{Key: SourceTopicHeader, Value: []byte(topic)},
+	)
+	ctx, cancel := context.WithTimeout(c.ctx, writeTimeout)
+	defer cancel()
+	return c.deadLetters.WriteMessages(ctx, kafkago.Message{Topic: c.deadLetterTopic, Key: msg.Key, Value: msg.Value, Headers: headers})
+}
+
+// Close stops consuming and leaves the consumer group.
+func (c *Consumer) Close() {
+	c.cancel()
+	c.done.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, reader := range c.readers {
+		if err := reader.Close(); err != nil {
+			logger.Log.Error().Err(err).Msg("Failed to close Kafka reader")
+		}
+	}
+	c.readers = nil
+	if c.deadLetters != nil {
+		if err := c.deadLetters.Close(); err != nil {
+			logger.Log.Error().Err(err).Msg("Failed to close Kafka dead-letter publisher")
+		}
+	}
+}
+
+// MessageType returns the event type header of the message.
+func MessageType(msg kafkago.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == TypeHeader {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,307 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go_services/pkg/retry"
+	"go_services/pkg/transport"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBroker is an in-process broker with partitioned topics and committed offsets per consumer group.
+type fakeBroker struct {
+	mu         sync.Mutex
+	partitions int
+	logs       map[string][][]kafkago.Message // topic -> partition -> messages
+	committed  map[string]map[string][]int64  // group -> topic -> partition -> next offset
+}
+
+func newFakeBroker(partitions int) *fakeBroker {
+	return &fakeBroker{
+		partitions: partitions,
+		logs:       make(map[string][][]kafkago.Message),
+		committed:  make(map[string]map[string][]int64),
+	}
+}
+
+func (b *fakeBroker) log(topic string) [][]kafkago.Message {
+	if _, ok := b.logs[topic]; !ok {
+		b.logs[topic] = make([][]kafkago.Message, b.partitions)
+	}
+	return b.logs[topic]
+}
+
+func (b *fakeBroker) offsets(group, topic string) []int64 {
+	if _, ok := b.committed[group]; !ok {
+		b.committed[group] = make(map[string][]int64)
+	}
+	if _, ok := b.committed[group][topic]; !ok {
+		b.committed[group][topic] = make([]int64, b.partitions)
+	}
+	return b.committed[group][topic]
+}
+
+// committedOffset returns the next offset the group consumes from the partition.
+func (b *fakeBroker) committedOffset(group, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offsets(group, topic)[partition]
+}
+
+type fakeWriter struct {
+	broker   *fakeBroker
+	balancer kafkago.Hash
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	w.broker.mu.Lock()
+	defer w.broker.mu.Unlock()
+	partitions := make([]int, w.broker.partitions)
+	for i := range partitions {
+		partitions[i] = i
+	}
+	for _, msg := range msgs {
+		log := w.broker.log(msg.Topic)
+		msg.Partition = w.balancer.Balance(msg, partitions...)
+		msg.Offset = int64(len(log[msg.Partition]))
+		log[msg.Partition] = append(log[msg.Partition], msg)
+	}
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+type fakeReader struct {
+	broker *fakeBroker
+	group  string
+	topic  string
+	next   []int64 // fetch position per partition
+}
+
+func (b *fakeBroker) reader(group, topic string) *fakeReader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &fakeReader{broker: b, group: group, topic: topic, next: append([]int64(nil), b.offsets(group, topic)...)}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	for {
+		r.broker.mu.Lock()
+		log := r.broker.log(r.topic)
+		for partition, messages := range log {
+			if r.next[partition] < int64(len(messages)) {
+				msg := messages[r.next[partition]]
+				r.next[partition]++
+				r.broker.mu.Unlock()
+				return msg, nil
+			}
+		}
+		r.broker.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafkago.Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	for _, msg := range msgs {
+		r.broker.offsets(r.group, msg.Topic)[msg.Partition] = msg.Offset + 1
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+type event struct {
+	VehiclePlate string `json:"vehicle_plate"`
+	Seq          int    `json:"seq"`
+}
+
+// recorder records the events it processes.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handler(eventType string) transport.Handler {
+	return handlerFunc(func(msg []byte) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, eventType+" "+string(msg))
+		return nil
+	})
+}
+
+func (r *recorder) processed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type handlerFunc func(msg []byte) error
+
+func (f handlerFunc) ProcessMessage(msg []byte) error { return f(msg) }
+
+func TestPublisher_PartitionsByKey(t *testing.T) {
+	broker := newFakeBroker(4)
+	publisher := &Publisher{writer: &fakeWriter{broker: broker}}
+
+	plates := []string{"ABC123", "XYZ789", "JKL456"}
+	for seq := 0; seq < 3; seq++ {
+		for _, plate := range plates {
+			assert.NoError(t, publisher.Publish("parking_events", plate, transport.EntryEvent, event{plate, seq}))
+		}
+	}
+
+	partitionOf := make(map[string]int)
+	for partition, messages := range broker.logs["parking_events"] {
+		for _, msg := range messages {
+			plate := string(msg.Key)
+			if seen, ok := partitionOf[plate]; ok {
+				assert.Equal(t, seen, partition, "events of %s in several partitions", plate)
+			}
+			partitionOf[plate] = partition
+			assert.Equal(t, transport.EntryEvent, MessageType(msg))
+			assert.Contains(t, string(msg.Value), fmt.Sprintf(`"vehicle_plate":"%s"`, plate))
+		}
+	}
+	assert.Len(t, partitionOf, len(plates))
+}
+
+func TestConsumer_ConsumeTyped(t *testing.T) {
+	broker := newFakeBroker(3)
+	publisher := &Publisher{writer: &fakeWriter{broker: broker}}
+	newReader := func(topic string) messageReader { return broker.reader("svc_backend", topic) }
+
+	for _, plate := range []string{"ABC123", "XYZ789"} {
+		assert.NoError(t, publisher.Publish("parking_events", plate, transport.EntryEvent, event{plate, 1}))
+		assert.NoError(t, publisher.Publish("parking_events", plate, transport.ExitEvent, event{plate, 2}))
+	}
+
+	// offsets are committed only after an event was processed
+	committedEarly := false
+	rec := &recorder{}
+	checkCommit := func(eventType string) transport.Handler {
+		next := rec.handler(eventType)
+		return handlerFunc(func(msg []byte) error {
+			for partition := 0; partition < broker.partitions; partition++ {
+				for _, logged := range broker.logs["parking_events"][partition] {
+					if string(logged.Value) == string(msg) && broker.committedOffset("svc_backend", "parking_events", partition) > logged.Offset {
+						committedEarly = true
+					}
+				}
+			}
+			return next.ProcessMessage(msg)
+		})
+	}
+	handlers := map[string]transport.Handler{transport.EntryEvent: checkCommit(transport.EntryEvent), transport.ExitEvent: checkCommit(transport.ExitEvent)}
+
+	consumer := newConsumer(newReader)
+	assert.NoError(t, consumer.ConsumeTyped("parking_events", handlers))
+	assert.Eventually(t, func() bool { return len(rec.processed()) == 4 }, time.Second, time.Millisecond)
+	consumer.Close()
+	assert.False(t, committedEarly)
+
+	// the events of a plate are processed in order
+	processed := rec.processed()
+	for _, plate := range []string{"ABC123", "XYZ789"} {
+		entry := fmt.Sprintf(`entry {"vehicle_plate":"%s","seq":1}`, plate)
+		exit := fmt.Sprintf(`exit {"vehicle_plate":"%s","seq":2}`, plate)
+		assert.Less(t, indexOf(processed, entry), indexOf(processed, exit))
+	}
+
+	// a restarted consumer of the group resumes after the committed offsets
+	assert.NoError(t, publisher.Publish("parking_events", "ABC123", transport.EntryEvent, event{"ABC123", 3}))
+	rec = &recorder{}
+	consumer = newConsumer(newReader)
+	assert.NoError(t, consumer.ConsumeTyped("parking_events", map[string]transport.Handler{transport.EntryEvent: rec.handler(transport.EntryEvent)}))
+	assert.Eventually(t, func() bool { return len(rec.processed()) == 1 }, time.Second, time.Millisecond)
+	consumer.Close()
+	assert.Equal(t, []string{`entry {"vehicle_plate":"ABC123","seq":3}`}, rec.processed())
+	assert.Error(t, consumer.Consume("parking_events", rec.handler(transport.EntryEvent)))
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestConsumer_FailingEvents(t *testing.T) {
+	tests := []struct {
+		name               string
+		failures           int
+		deadLetter         bool
+		expectedCommitted  int64
+		expectedDeadLetter bool
+	}{
+		{name: "Retried Until Processed", failures: 2, expectedCommitted: 1},
+		{name: "Dead Lettered", failures: 100, deadLetter: true, expectedCommitted: 1, expectedDeadLetter: true},
+		{name: "Kept Without Dead-Letter Topic", failures: 100, expectedCommitted: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker(1)
+			publisher := &Publisher{writer: &fakeWriter{broker: broker}}
+			assert.NoError(t, publisher.Publish("parking_events", "ABC123", transport.ExitEvent, event{"ABC123", 1}))
+
+			var mu sync.Mutex
+			attempts := 0
+			handler := handlerFunc(func(msg []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts++
+				if attempts <= tt.failures {
+					return fmt.Errorf("attempt %d failed", attempts)
+				}
+				return nil
+			})
+			attempted := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return attempts
+			}
+
+			consumer := newConsumer(func(topic string) messageReader { return broker.reader("svc_backend", topic) })
+			consumer.SetRetryPolicy(retry.Policy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			if tt.deadLetter {
+				consumer.deadLetters = &fakeWriter{broker: broker}
+				consumer.deadLetterTopic = "parking_dead_letters"
+			}
+			assert.NoError(t, consumer.Consume("parking_events", handler))
+			assert.Eventually(t, func() bool { return attempted() >= min(tt.failures+1, 3) }, time.Second, time.Millisecond)
+			if tt.expectedCommitted > 0 {
+				assert.Eventually(t, func() bool { return broker.committedOffset("svc_backend", "parking_events", 0) == tt.expectedCommitted }, time.Second, time.Millisecond)
+			}
+			consumer.Close()
+
+			assert.Equal(t, tt.expectedCommitted, broker.committedOffset("svc_backend", "parking_events", 0))
+			deadLetters := broker.logs["parking_dead_letters"]
+			if !tt.expectedDeadLetter {
+				assert.Empty(t, deadLetters)
+				return
+			}
+			assert.Len(t, deadLetters[0], 1)
+			msg := deadLetters[0][0]
+			assert.Equal(t, "ABC123", string(msg.Key))
+			assert.Equal(t, transport.ExitEvent, MessageType(msg))
+			assert.Contains(t, msg.Headers, kafkago.Header{Key: ErrorHeader, Value: []byte("attempt 3 failed")})
+			assert.Contains(t, msg.Headers, kafkago.Header{Key: SourceTopicHeader, Value: []byte("parking_events")})
+		})
+	}
+}
@@ -18,3 +18,13 @@ func InitLogger(logLevel string) {
 	Log = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
 
 }
+
+// SetLevel changes the global log level, e.g. on a configuration reload.
+func SetLevel(logLevel string) error {
+	level, err := zerolog.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package memstore is an in-memory, thread-safe stand-in for the Redis data operations used by the
+// services: hashes, lists and counters. Its contents can be snapshotted to a JSON file and loaded
+// back, so local runs survive restarts.
+package memstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go_services/pkg/logger"
+	"go_services/pkg/redis"
+)
+
+// Store holds hashes, lists and counters in memory.
+type Store struct {
+	mu       sync.RWMutex
+	hashes   map[string]map[string]string
+	lists    map[string][]string
+	counters map[string]int64
+}
+
+// snapshot is the on-disk format of a Store.
+type snapshot struct {
+	Hashes   map[string]map[string]string `json:"hashes"`
+	Lists    map[string][]string          `json:"lists"`
+	Counters map[string]int64             `json:"counters"`
+}
+
+// New creates an empty store.
+func New() *Store {
+	return &Store{
+		hashes:   make(map[string]map[string]string),
+		lists:    make(map[string][]string),
+		counters: make(map[string]int64),
+	}
+}
+
+// Load creates a store from the snapshot file at path; a missing file gives an empty store.
+func Load(path string) (*Store, error) {
+	store := New()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %v", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %v", path, err)
+	}
+	for key, fields := range snap.Hashes {
+		store.hashes[key] = fields
+	}
+	for key, items := range snap.Lists {
+		store.lists[key] = items
+	}
+	for key, value := range snap.Counters {
+		store.counters[key] = value
+	}
+	return store, nil
+}
+
+// SaveSnapshot writes the store contents to path, replacing the previous snapshot atomically.
+func (s *Store) SaveSnapshot(path string) error {
+	s.mu.RLock()
+	data, err := json.Marshal(snapshot{Hashes: s.hashes, Lists: s.lists, Counters: s.counters})
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing snapshot: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing snapshot: %v", err)
+	}
+	return nil
+}
+
+// RunSnapshots saves a snapshot to path every interval until stop is closed, and once more on stop.
+func (s *Store) RunSnapshots(path string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			if err := s.SaveSnapshot(path); err != nil {
+				logger.Log.Error().Err(err).Msg("Final snapshot failed")
+			}
+			return
+		case <-ticker.C:
+			if err := s.SaveSnapshot(path); err != nil {
+				logger.Log.Error().Err(err).Msg("Snapshot failed")
+			}
+		}
+	}
+}
+
+// AddFieldsToHash sets the given fields of the hash, creating it if needed.
+func (s *Store) AddFieldsToHash(hashKey string, fields map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, ok := s.hashes[hashKey]
+	if !ok {
+		hash = make(map[string]string)
+		s.hashes[hashKey] = hash
+	}
+	for field, value := range fields {
+		hash[field] = value
+	}
+	return nil
+}
+
+// GetAllFields returns a copy of all fields of the hash; a missing hash has no fields.
+func (s *Store) GetAllFields(hashKey string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fields := make(map[string]string, len(s.hashes[hashKey]))
+	for field, value := range s.hashes[hashKey] {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// ScanHashKeys returns the keys of all hashes matching the glob pattern, in order.
+func (s *Store) ScanHashKeys(pattern string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.hashes {
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hash keys: %v", err)
+		}
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// CloseSession writes the exit time to exitField and returns the entry time of the session it closes,
+// with the same semantics as redis.RedisClient.CloseSession.
+func (s *Store) CloseSession(hashKey string, entryField string, exitField string, exitTime time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash, ok := s.hashes[hashKey]
+	if !ok {
+		hash = make(map[string]string)
+		s.hashes[hashKey] = hash
+	}
+	notFound := fmt.Errorf("%w: open %s in hash %s", redis.ErrFieldNotFound, entryField, hashKey)
+
+	entryValue, hasEntry := hash[entryField]
+	if !hasEntry {
+		hash[exitField] = exitTime.Format(time.RFC3339Nano)
+		return time.Time{}, notFound
+	}
+	entryDateTime, err := time.Parse(time.RFC3339, entryValue)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time: %v", err)
+	}
+	if exitDateTime, err := time.Parse(time.RFC3339, hash[exitField]); err == nil && exitDateTime.After(entryDateTime) {
+		// Keep the exit that closed the session
+		return time.Time{}, notFound
+	}
+	if exitTime.Before(entryDateTime) {
+		// The exit happened before the stored entry, so it belongs to an earlier session
+		return time.Time{}, notFound
+	}
+
+	hash[exitField] = exitTime.Format(time.RFC3339Nano)
+	return entryDateTime, nil
+}
+
+// AppendToList appends an item to the end of the list.
+func (s *Store) AppendToList(listKey string, item string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lists[listKey] = append(s.lists[listKey], item)
+	return nil
+}
+
+// GetListItems returns a copy of all items of the list.
+func (s *Store) GetListItems(listKey string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.lists[listKey]...), nil
+}
+
+// TrimList keeps the last maxItems items of the list, dropping the older ones.
+func (s *Store) TrimList(listKey string, maxItems int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if items := s.lists[listKey]; len(items) > maxItems {
+		s.lists[listKey] = append([]string(nil), items[len(items)-maxItems:]...)
+	}
+	return nil
+}
+
+// DeleteKey removes the key, whatever its type.
+func (s *Store) DeleteKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.hashes, key)
+	delete(s.lists, key)
+	delete(s.counters, key)
+	return nil
+}
+
+// Increment increments the counter stored at key and returns the new value.
+func (s *Store) Increment(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[key]++
+	return s.counters[key], nil
+}
+
+// GetCounter returns the counter stored at key, 0 when it does not exist.
+func (s *Store) GetCounter(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counters[key], nil
+}
@@ -0,0 +1,101 @@
+package memstore
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go_services/pkg/redis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_SnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	store := New()
+	assert.NoError(t, store.AddFieldsToHash("ABC123", map[string]string{"entry_date_time": "2024-09-11T10:00:00Z"}))
+	assert.NoError(t, store.AppendToList("completed_sessions", `{"vehicle_plate":"XYZ789"}`))
+	_, err := store.Increment("invoice_sequence")
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SaveSnapshot(path))
+	restored, err := Load(path)
+
+	assert.NoError(t, err)
+	fields, _ := restored.GetAllFields("ABC123")
+	assert.Equal(t, map[string]string{"entry_date_time": "2024-09-11T10:00:00Z"}, fields)
+	items, _ := restored.GetListItems("completed_sessions")
+	assert.Equal(t, []string{`{"vehicle_plate":"XYZ789"}`}, items)
+	next, _ := restored.Increment("invoice_sequence")
+	assert.Equal(t, int64(2), next)
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	store, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+
+	assert.NoError(t, err)
+	keys, _ := store.ScanHashKeys("*")
+	assert.Empty(t, keys)
+}
+
+func TestStore_CloseSession(t *testing.T) {
+	entry := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	store := New()
+	assert.NoError(t, store.AddFieldsToHash("ABC123", map[string]string{"entry_date_time": entry.Format(time.RFC3339)}))
+
+	closedEntry, err := store.CloseSession("ABC123", "entry_date_time", "exit_date_time", entry.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, entry, closedEntry)
+
+	_, err = store.CloseSession("ABC123", "entry_date_time", "exit_date_time", entry.Add(2*time.Hour))
+	assert.ErrorIs(t, err, redis.ErrFieldNotFound)
+	fields, _ := store.GetAllFields("ABC123")
+	assert.Equal(t, entry.Add(time.Hour).Format(time.RFC3339Nano), fields["exit_date_time"], "the closing exit is kept")
+
+	_, err = store.CloseSession("NOENTRY", "entry_date_time", "exit_date_time", entry)
+	assert.ErrorIs(t, err, redis.ErrFieldNotFound)
+}
+
+func TestStore_ScanHashKeys(t *testing.T) {
+	store := New()
+	for _, key := range []string{"ABC123", "ABC124", "XYZ789"} {
+		assert.NoError(t, store.AddFieldsToHash(key, map[string]string{"entry_date_time": "x"}))
+	}
+	assert.NoError(t, store.AppendToList("ABC-list", "item"))
+
+	keys, err := store.ScanHashKeys("ABC*")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ABC123", "ABC124"}, keys)
+}
+
+func TestStore_ConcurrentUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	store := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				key := fmt.Sprintf("plate-%d", i)
+				assert.NoError(t, store.AddFieldsToHash(key, map[string]string{"n": fmt.Sprint(j)}))
+				assert.NoError(t, store.AppendToList("log", key))
+				_, err := store.Increment("sequence")
+				assert.NoError(t, err)
+				if j%10 == 0 {
+					assert.NoError(t, store.SaveSnapshot(path))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	items, _ := store.GetListItems("log")
+	assert.Len(t, items, 500)
+	next, _ := store.Increment("sequence")
+	assert.Equal(t, int64(501), next)
+}
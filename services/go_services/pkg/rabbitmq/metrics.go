@@ -0,0 +1,27 @@
+package rabbitmq
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	PublishLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rabbitmq_publish_latency_seconds",
+			Help:    "Latency of publishing an event until the broker confirmed it, in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"result"},
+	)
+
+	UnconfirmedPublishes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_unconfirmed_publishes_total",
+			Help: "Total number of published events the broker did not confirm, by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(PublishLatency)
+	prometheus.MustRegister(UnconfirmedPublishes)
+}
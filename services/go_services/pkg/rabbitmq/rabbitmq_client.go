@@ -1,18 +1,18 @@
 package rabbitmq
 
 import (
+	"crypto/tls"
+	"fmt"
 	"time"
 
 	"go_services/pkg/logger"
+	"go_services/pkg/retry"
 
 	"github.com/rabbitmq/amqp091-go"
 )
 
-const (
-	maxRetries     = 7                 // Maximum number of retries before giving up
-	initialBackoff = 3 * time.Second   // Initial delay before retrying
-	maxBackoff     = 200 * time.Second // Maximum delay between retries
-)
+// DefaultConnectRetry is the retry policy of Connect when none is set.
+var DefaultConnectRetry = retry.Policy{Attempts: 7, InitialBackoff: 3 * time.Second, MaxBackoff: 200 * time.Second}
 
 // RabbitMQClient holds the RabbitMQ connection and channel
 type RabbitMQClient struct {
@@ -22,17 +22,33 @@ type RabbitMQClient struct {
 
 // NewRabbitMQClient creates a new RabbitMQ client with retry logic
 func GetRabbitMQClient(url string) (*RabbitMQClient, error) {
+	return Connect(url, nil, DefaultConnectRetry)
+}
+
+// Connect creates a new RabbitMQ client, retrying the connection with the exponential backoff of policy
+// (DefaultConnectRetry when unset). amqps:// URLs are dialled with tlsConfig, e.g. to trust a custom CA
+// or present a client certificate, or with the system roots when it is nil.
+func Connect(url string, tlsConfig *tls.Config, policy retry.Policy) (*RabbitMQClient, error) {
 	var conn *amqp091.Connection
 	var ch *amqp091.Channel
 	var err error
 
-	for retries := 0; retries < maxRetries; retries++ {
-		conn, err = amqp091.Dial(url)
+	uri, err := amqp091.ParseURI(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RabbitMQ URL: %v", err)
+	}
+	if tlsConfig != nil && uri.Scheme != "amqps" {
+		return nil, fmt.Errorf("TLS is configured but the RabbitMQ URL does not use amqps://")
+	}
+
+	policy = policy.Or(DefaultConnectRetry)
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+		conn, err = amqp091.DialTLS(url, tlsConfig)
 		if err != nil {
-			backoff := time.Duration((1 << retries) * int(initialBackoff))
-			if backoff > maxBackoff {
-				backoff = maxBackoff
+			if attempt == policy.Attempts {
+				break
 			}
+			backoff := policy.Backoff(attempt)
 			logger.Log.Warn().Err(err).Msgf("Failed to connect to RabbitMQ, retrying in %v...", backoff)
 			time.Sleep(backoff)
 		} else {
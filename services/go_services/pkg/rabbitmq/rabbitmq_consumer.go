@@ -1,13 +1,36 @@
 package rabbitmq
 
-import "go_services/pkg/logger"
+import (
+	"fmt"
+	"strings"
 
-type Client interface {
-	ProcessMessage(msg []byte) error
+	"go_services/pkg/logger"
+	"go_services/pkg/transport"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Client processes consumed messages.
+type Client = transport.Handler
+
+var _ transport.Consumer = (*RabbitMQClient)(nil)
+
+// Consume consumes messages from the specified RabbitMQ queue and uses the provided handler.
+func (client *RabbitMQClient) Consume(queueName string, handler Client) error {
+	return client.consume(queueName, func(msg amqp091.Delivery) error {
+		return handler.ProcessMessage(msg.Body)
+	})
 }
 
-// ConsumeQueue consumes messages from the specified RabbitMQ queue and uses the provided handler.
-func (client *RabbitMQClient) ConsumeQueue(queueName string, handler Client) error {
+// ConsumeTyped consumes messages of several types from the specified RabbitMQ queue and hands each
+// to the handler of its type, so that events of different types keep their order.
+func (client *RabbitMQClient) ConsumeTyped(queueName string, handlers map[string]Client) error {
+	return client.consume(queueName, func(msg amqp091.Delivery) error {
+		return Dispatch(handlers, msg)
+	})
+}
+
+func (client *RabbitMQClient) consume(queueName string, handle func(msg amqp091.Delivery) error) error {
 	msgs, err := client.Channel.Consume(
 		queueName, // Queue
 		"",        // Consumer
@@ -23,7 +46,7 @@ func (client *RabbitMQClient) ConsumeQueue(queueName string, handler Client) err
 
 	go func() {
 		for msg := range msgs {
-			if err := handler.ProcessMessage(msg.Body); err != nil {
+			if err := handle(msg); err != nil {
 				logger.Log.Error().Err(err).Msg("Failed to process consumed message body ")
 			}
 		}
@@ -31,3 +54,23 @@ func (client *RabbitMQClient) ConsumeQueue(queueName string, handler Client) err
 
 	return nil
 }
+
+// Dispatch hands the message to the handler of its type.
+func Dispatch(handlers map[string]Client, msg amqp091.Delivery) error {
+	if err := transport.Dispatch(handlers, MessageType(msg), msg.Body); err != nil {
+		return fmt.Errorf("%v (routing key %q)", err, msg.RoutingKey)
+	}
+	return nil
+}
+
+// MessageType returns the type property of the message, or for untyped events the event type of their
+// routing key, e.g. exit for parking.main.exit.
+func MessageType(msg amqp091.Delivery) string {
+	if msg.Type != "" {
+		return msg.Type
+	}
+	if strings.HasPrefix(msg.RoutingKey, routingPrefix+".") {
+		return msg.RoutingKey[strings.LastIndex(msg.RoutingKey, ".")+1:]
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingClient struct {
+	messages []string
+}
+
+func (c *recordingClient) ProcessMessage(msg []byte) error {
+	c.messages = append(c.messages, string(msg))
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	entries, exits := &recordingClient{}, &recordingClient{}
+	handlers := map[string]Client{EntryEvent: entries, ExitEvent: exits}
+
+	deliveries := []amqp091.Delivery{
+		{Type: EntryEvent, RoutingKey: "vehicle_events", Body: []byte("entry 1")},
+		{Type: ExitEvent, RoutingKey: "vehicle_events", Body: []byte("exit 1")},
+		{RoutingKey: "parking.north.entry", Body: []byte("entry 2")}, // untyped, from the routing key
+		{RoutingKey: "parking.north.exit", Body: []byte("exit 2")},
+	}
+	for _, msg := range deliveries {
+		assert.NoError(t, Dispatch(handlers, msg))
+	}
+	assert.Equal(t, []string{"entry 1", "entry 2"}, entries.messages)
+	assert.Equal(t, []string{"exit 1", "exit 2"}, exits.messages)
+
+	assert.Error(t, Dispatch(handlers, amqp091.Delivery{Type: "validation"}))
+	assert.Error(t, Dispatch(handlers, amqp091.Delivery{RoutingKey: "vehicle_entries"}))
+}
@@ -1,40 +1,228 @@
 package rabbitmq
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"go_services/pkg/logger"
+	"go_services/pkg/transport"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rabbitmq/amqp091-go"
 )
 
-func PublishEvent(conn *amqp091.Connection, queueName string, eventPayload any) error {
-	channel, err := conn.Channel()
-	if err != nil {
-		return err
+const (
+	DefaultPublisherChannels = 4               // channels publishing concurrently
+	confirmTimeout           = 5 * time.Second // maximum wait for the broker to confirm a publish
+)
+
+var (
+	// ErrUnroutable is returned when a published event matched no queue.
+	ErrUnroutable = errors.New("event is unroutable")
+	// ErrNacked is returned when the broker could not take responsibility for a published event.
+	ErrNacked = errors.New("event was not acknowledged by the broker")
+	// errConfirmTimeout is returned when the broker did not confirm a publish in time.
+	errConfirmTimeout = errors.New("timed out waiting for the broker to confirm the event")
+)
+
+// amqpChannel is the part of *amqp091.Channel used to publish.
+type amqpChannel interface {
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp091.Confirmation) chan amqp091.Confirmation
+	NotifyReturn(returns chan amqp091.Return) chan amqp091.Return
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp091.Publishing) error
+	IsClosed() bool
+	Close() error
+}
+
+// confirmChannel is a channel in confirm mode with its confirmations and returns.
+type confirmChannel struct {
+	channel  amqpChannel
+	confirms chan amqp091.Confirmation
+	returns  chan amqp091.Return
+}
+
+// Publisher publishes persistent events on a pool of long-lived channels in confirm mode. An event is
+// published when the broker confirmed it; unroutable events are returned instead of silently dropped.
+type Publisher struct {
+	open    func() (amqpChannel, error)
+	pool    chan *confirmChannel // nil entries are opened on use
+	timeout time.Duration
+}
+
+// NewPublisher creates a publisher on the client's connection using up to channels channels at once.
+func (client *RabbitMQClient) NewPublisher(channels int) *Publisher {
+	return newPublisher(func() (amqpChannel, error) {
+		ch, err := client.Connection.Channel()
+		if err != nil {
+			return nil, err
+		}
+		return ch, nil
+	}, channels)
+}
+
+func newPublisher(open func() (amqpChannel, error), channels int) *Publisher {
+	if channels < 1 {
+		channels = 1
+	}
+	pool := make(chan *confirmChannel, channels)
+	for i := 0; i < channels; i++ {
+		pool <- nil
 	}
-	defer channel.Close()
+	return &Publisher{open: open, pool: pool, timeout: confirmTimeout}
+}
 
+// Publish publishes the event as JSON to the exchange with the routing key, or to the queue named by the
+// routing key through the default exchange, and waits for the broker to confirm it.
+func (p *Publisher) Publish(exchange, routingKey string, eventPayload any) error {
+	return p.PublishTyped(exchange, routingKey, "", eventPayload)
+}
+
+// PublishTyped publishes the event like Publish with the type property set to the event type, so that
+// consumers of queues with several types dispatch it.
+func (p *Publisher) PublishTyped(exchange, routingKey, eventType string, eventPayload any) error {
 	body, err := json.Marshal(eventPayload)
 	if err != nil {
 		logger.Log.Error().Err(err).Msgf("JSON conversion error in %v...", eventPayload)
 		return err
 	}
 
-	err = channel.Publish(
-		"",        // exchange
-		queueName, // routing key (queue name)
-		false,     // mandatory
-		false,     // immediate
+	start := time.Now()
+	channel := <-p.pool
+	err = p.publish(&channel, exchange, routingKey, eventType, body)
+	p.pool <- channel
+
+	// metrics instrumentation: publish latency and unconfirmed events
+	if err != nil {
+		PublishLatency.With(prometheus.Labels{"result": "unconfirmed"}).Observe(time.Since(start).Seconds())
+		UnconfirmedPublishes.With(prometheus.Labels{"reason": unconfirmedReason(err)}).Inc()
+		return fmt.Errorf("error publishing to exchange %q with routing key %q: %w", exchange, routingKey, err)
+	}
+	PublishLatency.With(prometheus.Labels{"result": "confirmed"}).Observe(time.Since(start).Seconds())
+
+	logger.Log.Info().Msgf("Published event: %s", body)
+	return nil
+}
+
+// publish publishes on the pooled channel, opening it when needed. A channel whose state is unknown
+// after a failure is closed and cleared, so that a late confirm is not taken for the next event's.
+func (p *Publisher) publish(channel **confirmChannel, exchange, routingKey, eventType string, body []byte) error {
+	if *channel == nil || (*channel).channel.IsClosed() {
+		opened, err := p.openChannel()
+		if err != nil {
+			*channel = nil
+			return err
+		}
+		*channel = opened
+	}
+
+	err := (*channel).publish(exchange, routingKey, eventType, body, p.timeout)
+	if err != nil && !errors.Is(err, ErrUnroutable) && !errors.Is(err, ErrNacked) {
+		(*channel).channel.Close()
+		*channel = nil
+	}
+	return err
+}
+
+func (p *Publisher) openChannel() (*confirmChannel, error) {
+	ch, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("error enabling publisher confirms: %v", err)
+	}
+	return &confirmChannel{
+		channel:  ch,
+		confirms: ch.NotifyPublish(make(chan amqp091.Confirmation, 1)),
+		returns:  ch.NotifyReturn(make(chan amqp091.Return, 1)),
+	}, nil
+}
+
+// publish publishes a mandatory, persistent message and waits for its confirm. The broker sends the
+// return of an unroutable message before its confirm, so it is buffered by the time the confirm arrives.
+func (c *confirmChannel) publish(exchange, routingKey, eventType string, body []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := c.channel.PublishWithContext(ctx,
+		exchange,   // exchange
+		routingKey, // routing key (queue name for the default exchange)
+		true,       // mandatory
+		false,      // immediate
 		amqp091.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			ContentType:  "application/json",
+			DeliveryMode: amqp091.Persistent,
+			Type:         eventType,
+			Timestamp:    time.Now(),
+			Body:         body,
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	logger.Log.Info().Msgf("Published event: %s", body)
-	return nil
+	select {
+	case confirm, ok := <-c.confirms:
+		if !ok {
+			return errors.New("channel closed before the event was confirmed")
+		}
+		select {
+		case returned, ok := <-c.returns:
+			if ok {
+				return fmt.Errorf("%w: %s", ErrUnroutable, returned.ReplyText)
+			}
+		default:
+		}
+		if !confirm.Ack {
+			return ErrNacked
+		}
+		return nil
+	case <-ctx.Done():
+		return errConfirmTimeout
+	}
+}
+
+// ExchangePublisher publishes to one exchange through the transport-neutral interface, with the
+// destination as routing key; with the default exchange the destination is the queue name. RabbitMQ
+// routes by routing key only, so the partitioning key is not used.
+type ExchangePublisher struct {
+	Publisher *Publisher
+	Exchange  string
+}
+
+var _ transport.Publisher = (*ExchangePublisher)(nil)
+
+func (p *ExchangePublisher) Publish(destination, key, eventType string, eventPayload any) error {
+	return p.Publisher.PublishTyped(p.Exchange, destination, eventType, eventPayload)
+}
+
+// Close closes the publisher's channels; later events are published on new channels.
+func (p *Publisher) Close() {
+	for i := 0; i < cap(p.pool); i++ {
+		channel := <-p.pool
+		if channel != nil {
+			if err := channel.channel.Close(); err != nil {
+				logger.Log.Error().Err(err).Msg("Failed to close RabbitMQ publisher channel")
+			}
+		}
+		p.pool <- nil
+	}
+}
+
+func unconfirmedReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnroutable):
+		return "returned"
+	case errors.Is(err, ErrNacked):
+		return "nacked"
+	case errors.Is(err, errConfirmTimeout):
+		return "timeout"
+	default:
+		return "error"
+	}
 }
@@ -0,0 +1,109 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChannel confirms publishes like a broker with the given bindings: routing keys without one are
+// returned before they are confirmed.
+type fakeChannel struct {
+	routable  map[string]bool
+	nack      bool
+	noConfirm bool
+	closed    bool
+	published []amqp091.Publishing
+	confirms  chan amqp091.Confirmation
+	returns   chan amqp091.Return
+}
+
+func (c *fakeChannel) Confirm(noWait bool) error { return nil }
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp091.Confirmation) chan amqp091.Confirmation {
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *fakeChannel) NotifyReturn(returns chan amqp091.Return) chan amqp091.Return {
+	c.returns = returns
+	return returns
+}
+
+func (c *fakeChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp091.Publishing) error {
+	c.published = append(c.published, msg)
+	tag := uint64(len(c.published))
+	if mandatory && !c.routable[key] {
+		c.returns <- amqp091.Return{RoutingKey: key, ReplyText: "NO_ROUTE"}
+	}
+	if !c.noConfirm {
+		c.confirms <- amqp091.Confirmation{DeliveryTag: tag, Ack: !c.nack}
+	}
+	return nil
+}
+
+func (c *fakeChannel) IsClosed() bool { return c.closed }
+
+func (c *fakeChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	tests := []struct {
+		name          string
+		channel       *fakeChannel
+		routingKey    string
+		expectedError error
+		reopened      bool
+	}{
+		{"Confirmed", &fakeChannel{routable: map[string]bool{"vehicle_entries": true}}, "vehicle_entries", nil, false},
+		{"Unroutable", &fakeChannel{}, "parking.main.entry", ErrUnroutable, false},
+		{"Nacked", &fakeChannel{routable: map[string]bool{"vehicle_entries": true}, nack: true}, "vehicle_entries", ErrNacked, false},
+		{"Not Confirmed In Time", &fakeChannel{routable: map[string]bool{"vehicle_entries": true}, noConfirm: true}, "vehicle_entries", errConfirmTimeout, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened := 0
+			publisher := newPublisher(func() (amqpChannel, error) {
+				opened++
+				return tt.channel, nil
+			}, 1)
+			publisher.timeout = 10 * time.Millisecond
+
+			err := publisher.Publish("", tt.routingKey, map[string]string{"id": "1"})
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, tt.channel.published, 1)
+			assert.Equal(t, amqp091.Persistent, tt.channel.published[0].DeliveryMode)
+			assert.JSONEq(t, `{"id":"1"}`, string(tt.channel.published[0].Body))
+
+			// the channel is reused unless its state is unknown
+			tt.channel.noConfirm = false
+			tt.channel.closed = false
+			publisher.Publish("", tt.routingKey, map[string]string{"id": "2"})
+			if tt.reopened {
+				assert.Equal(t, 2, opened)
+			} else {
+				assert.Equal(t, 1, opened)
+			}
+		})
+	}
+}
+
+func TestPublisher_OpenError(t *testing.T) {
+	publisher := newPublisher(func() (amqpChannel, error) {
+		return nil, errors.New("connection closed")
+	}, 2)
+
+	assert.Error(t, publisher.Publish("parking", "parking.main.exit", map[string]string{}))
+	publisher.Close()
+}
@@ -0,0 +1,122 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	"go_services/pkg/logger"
+	"go_services/pkg/retry"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// streamPrefetch is the number of unacknowledged stream messages delivered at once.
+const streamPrefetch = 100
+
+// streamRetry spaces the attempts to process a failing stream message. A stream cannot requeue a
+// message, so it is retried until it succeeds instead of being skipped.
+var streamRetry = retry.Policy{InitialBackoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// OffsetStore keeps the offset of the last processed message of each stream.
+type OffsetStore interface {
+	LoadOffset(stream string) (offset int64, ok bool, err error)
+	SaveOffset(stream string, offset int64) error
+}
+
+// StreamHandler processes a stream message. replayed reports whether the message was processed
+// before, i.e. its offset is not after the stored one or, without a stored offset, it was published
+// before the consumer attached.
+type StreamHandler func(msg amqp091.Delivery, replayed bool) error
+
+// ConsumeStream consumes the stream queue from the message after the stored offset, or from the next
+// published message when no offset is stored, and stores the offset of each processed message. The
+// messages stored before the first start are not processed, as their effects cannot be told apart from
+// those of an earlier consumer. With a non-zero replayFrom it starts at the messages stored since then
+// instead, handing the ones up to the stored offset over as replayed. Without a stored offset the
+// messages published before the consumer attached are the replayed ones.
+func (client *RabbitMQClient) ConsumeStream(stream string, offsets OffsetStore, replayFrom time.Time, handle StreamHandler) error {
+	committed, stored, err := offsets.LoadOffset(stream)
+	if err != nil {
+		return fmt.Errorf("error loading the offset of stream %s: %v", stream, err)
+	}
+
+	// streams are consumed with manual acknowledgements and a prefetch limit on their own channel
+	ch, err := client.Connection.Channel()
+	if err != nil {
+		return err
+	}
+	if err := ch.Qos(streamPrefetch, 0, false); err != nil {
+		ch.Close()
+		return err
+	}
+	start := streamStart(committed, stored, replayFrom)
+	attached := time.Now()
+	msgs, err := ch.Consume(
+		stream, // Queue
+		"",     // Consumer
+		false,  // Auto-ack
+		false,  // Exclusive
+		false,  // No-local
+		false,  // No-wait
+		amqp091.Table{"x-stream-offset": start},
+	)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+	logger.Log.Info().Msgf("Consuming stream %s from %v", stream, start)
+
+	var replayBefore time.Time
+	if !replayFrom.IsZero() && !stored {
+		replayBefore = attached
+	}
+	go consumeStream(stream, msgs, offsets, committed, stored, replayBefore, streamRetry, handle)
+	return nil
+}
+
+// streamStart returns the x-stream-offset to attach at.
+func streamStart(committed int64, stored bool, replayFrom time.Time) any {
+	switch {
+	case !replayFrom.IsZero():
+		return replayFrom
+	case stored:
+		return committed + 1
+	default:
+		return "next"
+	}
+}
+
+// consumeStream processes the messages, acknowledging each and storing the offsets past the committed one.
+// Messages published before a non-zero replayBefore are replayed too; their AMQP timestamp has second
+// precision and is missing on events published without one, which count as replayed. A failing message
+// is retried per policy, ignoring its attempts, so neither it nor the offset after it is acknowledged
+// before it is processed.
+func consumeStream(stream string, msgs <-chan amqp091.Delivery, offsets OffsetStore, committed int64, stored bool, replayBefore time.Time, policy retry.Policy, handle StreamHandler) {
+	replayBefore = replayBefore.Truncate(time.Second)
+	for msg := range msgs {
+		offset, ok := msg.Headers["x-stream-offset"].(int64)
+		replayed := (ok && stored && offset <= committed) || (!replayBefore.IsZero() && msg.Timestamp.Before(replayBefore))
+
+		for attempt := 1; ; attempt++ {
+			err := handle(msg, replayed)
+			if err == nil {
+				break
+			}
+			wait := policy.Backoff(attempt)
+			logger.Log.Error().Err(err).Msgf("Failed to process offset %d of stream %s (attempt %d), retrying in %v", offset, stream, attempt, wait)
+			time.Sleep(wait)
+		}
+		if err := msg.Ack(false); err != nil {
+			logger.Log.Error().Err(err).Msgf("Failed to acknowledge message of stream %s", stream)
+		}
+
+		if !ok || replayed {
+			continue
+		}
+		if err := offsets.SaveOffset(stream, offset); err != nil {
+			logger.Log.Error().Err(err).Msgf("Failed to store offset %d of stream %s", offset, stream)
+			continue
+		}
+		committed, stored = offset, true
+	}
+}
@@ -0,0 +1,114 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_services/pkg/retry"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryOffsets map[string]int64
+
+func (m memoryOffsets) LoadOffset(stream string) (int64, bool, error) {
+	offset, ok := m[stream]
+	return offset, ok, nil
+}
+
+func (m memoryOffsets) SaveOffset(stream string, offset int64) error {
+	m[stream] = offset
+	return nil
+}
+
+type countingAcknowledger struct {
+	acks int
+}
+
+func (a *countingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acks++
+	return nil
+}
+
+func (a *countingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error { return nil }
+
+func (a *countingAcknowledger) Reject(tag uint64, requeue bool) error { return nil }
+
+func TestStreamStart(t *testing.T) {
+	from := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "next", streamStart(0, false, time.Time{}))
+	assert.Equal(t, int64(43), streamStart(42, true, time.Time{}))
+	assert.Equal(t, from, streamStart(42, true, from))
+}
+
+func TestConsumeStream(t *testing.T) {
+	attached := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		offsets          memoryOffsets
+		replayBefore     time.Time
+		expectedReplayed []bool
+		expectedOffset   int64
+	}{
+		{"First Run", memoryOffsets{}, time.Time{}, []bool{false, false, false}, 12},
+		{"Replay Before Stored Offset", memoryOffsets{"vehicle_events": 11}, time.Time{}, []bool{true, true, false}, 12},
+		{"Replay Of Processed Messages", memoryOffsets{"vehicle_events": 20}, time.Time{}, []bool{true, true, true}, 20},
+		{"Replay Without Stored Offset", memoryOffsets{}, attached, []bool{true, true, false}, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acknowledger := &countingAcknowledger{}
+			msgs := make(chan amqp091.Delivery, 3)
+			for offset := int64(10); offset <= 12; offset++ {
+				msgs <- amqp091.Delivery{
+					Acknowledger: acknowledger,
+					Headers:      amqp091.Table{"x-stream-offset": offset},
+					Timestamp:    attached.Add(time.Duration(offset-12) * time.Minute),
+				}
+			}
+			close(msgs)
+
+			var replayed []bool
+			committed, stored, _ := tt.offsets.LoadOffset("vehicle_events")
+			consumeStream("vehicle_events", msgs, tt.offsets, committed, stored, tt.replayBefore, retry.Policy{}, func(msg amqp091.Delivery, isReplay bool) error {
+				replayed = append(replayed, isReplay)
+				return nil
+			})
+
+			assert.Equal(t, tt.expectedReplayed, replayed)
+			assert.Equal(t, tt.expectedOffset, tt.offsets["vehicle_events"])
+			assert.Equal(t, 3, acknowledger.acks)
+		})
+	}
+}
+
+func TestConsumeStream_RetriesFailedMessage(t *testing.T) {
+	acknowledger := &countingAcknowledger{}
+	offsets := memoryOffsets{"vehicle_events": 9}
+	msgs := make(chan amqp091.Delivery, 2)
+	for offset := int64(10); offset <= 11; offset++ {
+		msgs <- amqp091.Delivery{Acknowledger: acknowledger, Headers: amqp091.Table{"x-stream-offset": offset}}
+	}
+	close(msgs)
+
+	var handled []int64
+	failures := 2
+	consumeStream("vehicle_events", msgs, offsets, 9, true, time.Time{}, retry.Policy{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, func(msg amqp091.Delivery, isReplay bool) error {
+		offset := msg.Headers["x-stream-offset"].(int64)
+		handled = append(handled, offset)
+		if offset == 10 && failures > 0 {
+			failures--
+			assert.Equal(t, int64(9), offsets["vehicle_events"])
+			assert.Zero(t, acknowledger.acks)
+			return errors.New("storage unavailable")
+		}
+		return nil
+	})
+
+	assert.Equal(t, []int64{10, 10, 10, 11}, handled)
+	assert.Equal(t, int64(11), offsets["vehicle_events"])
+	assert.Equal(t, 2, acknowledger.acks)
+}
@@ -0,0 +1,31 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"strings"
+
+	"go_services/pkg/transport"
+)
+
+// Event types, the last word of the routing keys
+const (
+	EntryEvent = transport.EntryEvent
+	ExitEvent  = transport.ExitEvent
+)
+
+// routingPrefix is the first word of the routing keys of parking events.
+const routingPrefix = "parking"
+
+// EventRoutingKey returns the routing key of a facility's events of a type, e.g. parking.north.entry.
+// Consumers bind with patterns such as parking.*.exit or parking.north.#.
+func EventRoutingKey(facility, eventType string) string {
+	return routingPrefix + "." + facility + "." + eventType
+}
+
+// ValidateRoutingWord checks that a value, e.g. a facility, is a single word of a routing key.
+func ValidateRoutingWord(word string) error {
+	if word == "" || strings.ContainsAny(word, ".*#") {
+		return fmt.Errorf("%q must be a non-empty routing key word without '.', '*' or '#'", word)
+	}
+	return nil
+}
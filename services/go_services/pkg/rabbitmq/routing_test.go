@@ -0,0 +1,17 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventRoutingKey(t *testing.T) {
+	assert.Equal(t, "parking.north.entry", EventRoutingKey("north", EntryEvent))
+	assert.Equal(t, "parking.north.exit", EventRoutingKey("north", ExitEvent))
+
+	assert.NoError(t, ValidateRoutingWord("north-gate_2"))
+	for _, word := range []string{"", "north.gate", "*", "north#"} {
+		assert.Error(t, ValidateRoutingWord(word), word)
+	}
+}
@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go_services/pkg/logger"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Queue types
+const (
+	ClassicQueue = "classic"
+	QuorumQueue  = "quorum"
+	StreamQueue  = "stream"
+)
+
+// QueueOptions are the arguments of a declared queue. Zero values leave the broker defaults.
+type QueueOptions struct {
+	Type               string        // ClassicQueue or QuorumQueue
+	MessageTTL         time.Duration // messages expire after the TTL
+	MaxLength          int64         // the oldest messages are dropped beyond the length
+	DeadLetterExchange string        // expired, dropped and rejected messages are republished to it
+	MaxAge             time.Duration // streams only: messages are discarded after the age
+}
+
+// Queue is a durable queue.
+type Queue struct {
+	Name    string
+	Options QueueOptions
+}
+
+// Exchange is a durable exchange of a kind such as "direct", "fanout" or "topic".
+type Exchange struct {
+	Name string
+	Kind string
+}
+
+// Binding routes the messages of an exchange matching the routing key to a queue.
+type Binding struct {
+	Queue      string
+	Exchange   string
+	RoutingKey string
+}
+
+// Topology is the set of exchanges, queues and bindings a service relies on.
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+// Arguments returns the queue arguments for the options.
+func (o QueueOptions) Arguments() (amqp091.Table, error) {
+	args := amqp091.Table{}
+	switch o.Type {
+	case "", ClassicQueue:
+	case QuorumQueue:
+		args["x-queue-type"] = QuorumQueue
+	case StreamQueue:
+		if o.MessageTTL != 0 || o.MaxLength != 0 || o.DeadLetterExchange != "" {
+			return nil, errors.New("stream queues support no message TTL, max length or dead lettering")
+		}
+		args["x-queue-type"] = StreamQueue
+	default:
+		return nil, fmt.Errorf("unknown queue type %q", o.Type)
+	}
+	if o.MessageTTL < 0 || o.MaxLength < 0 || o.MaxAge < 0 {
+		return nil, errors.New("queue message TTL, max length and max age must not be negative")
+	}
+	if o.MaxAge > 0 {
+		if o.Type != StreamQueue {
+			return nil, errors.New("max age applies to stream queues only")
+		}
+		args["x-max-age"] = fmt.Sprintf("%ds", int64(o.MaxAge.Seconds()))
+	}
+	if o.MessageTTL > 0 {
+		args["x-message-ttl"] = o.MessageTTL.Milliseconds()
+	}
+	if o.MaxLength > 0 {
+		args["x-max-length"] = o.MaxLength
+	}
+	if o.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = o.DeadLetterExchange
+	}
+	return args, nil
+}
+
+// DeclareTopology declares the exchanges, queues and bindings of the topology. Declaring is idempotent,
+// but fails when an exchange or queue already exists with other properties or arguments, e.g. a classic
+// queue declared as quorum queue; the existing one must then be deleted or the configuration aligned.
+func (client *RabbitMQClient) DeclareTopology(topology Topology) error {
+	for _, exchange := range topology.Exchanges {
+		err := client.declare(func(ch *amqp091.Channel) error {
+			return ch.ExchangeDeclare(exchange.Name, exchange.Kind, true, false, false, false, nil)
+		})
+		if err != nil {
+			return topologyError("exchange", exchange.Name, err)
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		args, err := queue.Options.Arguments()
+		if err != nil {
+			return fmt.Errorf("invalid queue %s: %v", queue.Name, err)
+		}
+		err = client.declare(func(ch *amqp091.Channel) error {
+			_, err := ch.QueueDeclare(queue.Name, true, false, false, false, args)
+			return err
+		})
+		if err != nil {
+			return topologyError("queue", queue.Name, err)
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		err := client.declare(func(ch *amqp091.Channel) error {
+			return ch.QueueBind(binding.Queue, binding.RoutingKey, binding.Exchange, false, nil)
+		})
+		if err != nil {
+			return fmt.Errorf("error binding queue %s to exchange %s with %q: %v", binding.Queue, binding.Exchange, binding.RoutingKey, err)
+		}
+	}
+
+	logger.Log.Info().Msgf("Declared %d exchanges, %d queues and %d bindings",
+		len(topology.Exchanges), len(topology.Queues), len(topology.Bindings))
+	return nil
+}
+
+// declare runs a declaration on its own channel, as the broker closes the channel of a failed one.
+func (client *RabbitMQClient) declare(declaration func(ch *amqp091.Channel) error) error {
+	ch, err := client.Connection.Channel()
+	if err != nil {
+		return err
+	}
+	if err := declaration(ch); err != nil {
+		return err
+	}
+	return ch.Close()
+}
+
+func topologyError(kind, name string, err error) error {
+	var amqpErr *amqp091.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp091.PreconditionFailed {
+		return fmt.Errorf("%s %s exists with other settings than configured; delete it or align the configuration: %s", kind, name, amqpErr.Reason)
+	}
+	return fmt.Errorf("error declaring %s %s: %v", kind, name, err)
+}
+
+// NewTopology returns the topology of the named queues, all declared with the same options; empty
+// names are skipped. With a dead letter exchange, the exchange is declared as fanout exchange and
+// bound to deadLetterQueue when one is named.
+func NewTopology(queueNames []string, options QueueOptions, deadLetterQueue string) Topology {
+	var topology Topology
+	declared := make(map[string]bool)
+	for _, name := range queueNames {
+		if name == "" || declared[name] {
+			continue
+		}
+		declared[name] = true
+		topology.Queues = append(topology.Queues, Queue{Name: name, Options: options})
+	}
+
+	if options.DeadLetterExchange == "" {
+		return topology
+	}
+	topology.Exchanges = append(topology.Exchanges, Exchange{Name: options.DeadLetterExchange, Kind: amqp091.ExchangeFanout})
+	if deadLetterQueue != "" {
+		// dead letters are kept until consumed rather than expired or dead lettered again
+		topology.Queues = append(topology.Queues, Queue{Name: deadLetterQueue, Options: QueueOptions{Type: options.Type}})
+		topology.Bindings = append(topology.Bindings, Binding{Queue: deadLetterQueue, Exchange: options.DeadLetterExchange})
+	}
+	return topology
+}
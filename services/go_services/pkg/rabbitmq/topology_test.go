@@ -0,0 +1,75 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueOptions_Arguments(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  QueueOptions
+		expected amqp091.Table
+		wantErr  bool
+	}{
+		{"defaults", QueueOptions{}, amqp091.Table{}, false},
+		{"classic", QueueOptions{Type: ClassicQueue}, amqp091.Table{}, false},
+		{
+			"quorum with limits and dead letters",
+			QueueOptions{Type: QuorumQueue, MessageTTL: 90 * time.Second, MaxLength: 1000, DeadLetterExchange: "parking.dlx"},
+			amqp091.Table{
+				"x-queue-type":           "quorum",
+				"x-message-ttl":          int64(90000),
+				"x-max-length":           int64(1000),
+				"x-dead-letter-exchange": "parking.dlx",
+			},
+			false,
+		},
+		{
+			"stream with max age",
+			QueueOptions{Type: StreamQueue, MaxAge: 7 * 24 * time.Hour},
+			amqp091.Table{"x-queue-type": "stream", "x-max-age": "604800s"},
+			false,
+		},
+		{"stream with max length", QueueOptions{Type: StreamQueue, MaxLength: 1000}, nil, true},
+		{"max age of classic queue", QueueOptions{MaxAge: time.Hour}, nil, true},
+		{"unknown type", QueueOptions{Type: "lazy"}, nil, true},
+		{"negative ttl", QueueOptions{MessageTTL: -time.Second}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.options.Arguments()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, args)
+			assert.NoError(t, args.Validate())
+		})
+	}
+}
+
+func TestNewTopology(t *testing.T) {
+	options := QueueOptions{Type: QuorumQueue, MaxLength: 10}
+	topology := NewTopology([]string{"vehicle_entries", "", "vehicle_exits", "vehicle_entries"}, options, "")
+	assert.Equal(t, Topology{Queues: []Queue{
+		{Name: "vehicle_entries", Options: options},
+		{Name: "vehicle_exits", Options: options},
+	}}, topology)
+
+	options.DeadLetterExchange = "parking.dlx"
+	topology = NewTopology([]string{"vehicle_entries"}, options, "parking_dead_letters")
+	assert.Equal(t, Topology{
+		Exchanges: []Exchange{{Name: "parking.dlx", Kind: "fanout"}},
+		Queues: []Queue{
+			{Name: "vehicle_entries", Options: options},
+			{Name: "parking_dead_letters", Options: QueueOptions{Type: QuorumQueue}},
+		},
+		Bindings: []Binding{{Queue: "parking_dead_letters", Exchange: "parking.dlx"}},
+	}, topology)
+}
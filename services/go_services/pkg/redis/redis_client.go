@@ -2,37 +2,164 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"strings"
 	"time"
 
 	"go_services/pkg/logger"
+	"go_services/pkg/retry"
+	"go_services/pkg/tlsconfig"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// DefaultConnectRetry is the retry policy of Connect when the options set none.
+var DefaultConnectRetry = retry.Policy{Attempts: 5, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+// Redis topologies supported by Connect
 const (
-	maxRetries     = 5                // Maximum number of retries before giving up
-	initialBackoff = 2 * time.Second  // Initial delay before retrying
-	maxBackoff     = 30 * time.Second // Maximum delay between retries
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
 )
 
-// RedisClient is a wrapper around the redis.Client to hold the instance
+// Options describes how to reach Redis. Addrs holds the server address for a standalone server, the
+// sentinel addresses for Sentinel failover, or the seed nodes of a cluster. Username selects a Redis
+// ACL user; without it Password authenticates the default user. A non-nil TLS encrypts all connections,
+// including those to the sentinels.
+type Options struct {
+	Mode             string
+	Addrs            []string
+	Username         string
+	Password         string
+	DB               int
+	MasterName       string // name of the master monitored by the sentinels
+	SentinelPassword string
+	TLS              *tls.Config
+	Retry            retry.Policy // of Connect; DefaultConnectRetry when unset
+}
+
+// Settings are the Redis connection settings as configured: Address is a comma separated list of
+// addresses and the TLS files are loaded only when TLS is enabled.
+type Settings struct {
+	Mode             string
+	Address          string
+	Username         string
+	Password         string
+	DB               int
+	SentinelMaster   string
+	SentinelPassword string
+	TLS              bool
+	TLSFiles         tlsconfig.Files
+	ConnectRetry     retry.Policy
+}
+
+// NewOptions returns the connection options of the settings.
+func NewOptions(settings Settings) (Options, error) {
+	opts := Options{
+		Mode:             settings.Mode,
+		Addrs:            ParseAddrs(settings.Address),
+		Username:         settings.Username,
+		Password:         settings.Password,
+		DB:               settings.DB,
+		MasterName:       settings.SentinelMaster,
+		SentinelPassword: settings.SentinelPassword,
+		Retry:            settings.ConnectRetry,
+	}
+	if !settings.TLS {
+		return opts, nil
+	}
+
+	tlsConfig, err := tlsconfig.Load(settings.TLSFiles)
+	if err != nil {
+		return Options{}, fmt.Errorf("error loading Redis TLS configuration: %v", err)
+	}
+	opts.TLS = tlsConfig
+	return opts, nil
+}
+
+// RedisClient is a wrapper around the redis.UniversalClient to hold the instance; its methods work on
+// standalone servers, Sentinel failover and clusters alike.
 type RedisClient struct {
-	Client *redis.Client
+	Client redis.UniversalClient
+}
+
+// ParseAddrs splits a comma separated list of addresses.
+func ParseAddrs(addrs string) []string {
+	var parsed []string
+	for _, addr := range strings.Split(addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			parsed = append(parsed, addr)
+		}
+	}
+	return parsed
+}
+
+// NewClient creates the client for the topology in opts without connecting to it.
+func NewClient(opts Options) (redis.UniversalClient, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, fmt.Errorf("no Redis address configured")
+	}
+
+	switch opts.Mode {
+	case ModeStandalone, "":
+		if len(opts.Addrs) > 1 {
+			return nil, fmt.Errorf("standalone Redis takes one address, got %d", len(opts.Addrs))
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:      opts.Addrs[0],
+			Username:  opts.Username,
+			Password:  opts.Password,
+			DB:        opts.DB,
+			TLSConfig: opts.TLS,
+		}), nil
+	case ModeSentinel:
+		if opts.MasterName == "" {
+			return nil, fmt.Errorf("sentinel mode needs the name of the master")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       opts.MasterName,
+			SentinelAddrs:    opts.Addrs,
+			SentinelPassword: opts.SentinelPassword,
+			Username:         opts.Username,
+			Password:         opts.Password,
+			DB:               opts.DB,
+			TLSConfig:        opts.TLS,
+		}), nil
+	case ModeCluster:
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("redis cluster only has database 0, got %d", opts.DB)
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     opts.Addrs,
+			Username:  opts.Username,
+			Password:  opts.Password,
+			TLSConfig: opts.TLS,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", opts.Mode)
+	}
 }
 
-// Function to connect to Redis with retry and exponential backoff
+// Function to connect to a standalone Redis server with retry and exponential backoff
 func GetRedisClient(addr string, pword string, database int) (*RedisClient, error) {
-	var client *redis.Client
+	return Connect(Options{Mode: ModeStandalone, Addrs: []string{addr}, Password: pword, DB: database})
+}
+
+// Connect connects to Redis in the topology described by opts, retrying with the exponential backoff
+// of opts.Retry
+func Connect(opts Options) (*RedisClient, error) {
+	var client redis.UniversalClient
 	var err error
 
-	for retries := 0; retries < maxRetries; retries++ {
-		client = redis.NewClient(&redis.Options{
-			Addr:     addr,
-			Password: pword,
-			DB:       database,
-		})
-		logger.Log.Info().Msg(" connecting to Redis")
+	policy := opts.Retry.Or(DefaultConnectRetry)
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+		client, err = NewClient(opts)
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.Info().Msgf(" connecting to Redis (%s)", opts.Mode)
 		ctx := context.Background()
 		_, err = client.Ping(ctx).Result()
 		if err == nil {
@@ -40,10 +167,11 @@ func GetRedisClient(addr string, pword string, database int) (*RedisClient, erro
 			logger.Log.Info().Msg("Successfully connected to Redis")
 			return &RedisClient{Client: client}, nil
 		} else {
-			backoff := time.Duration((1 << retries) * int(initialBackoff))
-			if backoff > maxBackoff {
-				backoff = maxBackoff
+			client.Close()
+			if attempt == policy.Attempts {
+				break
 			}
+			backoff := policy.Backoff(attempt)
 			logger.Log.Warn().Err(err).Msgf("Failed to connect to Redis, retrying in %v...", backoff)
 			time.Sleep(backoff)
 		}
@@ -51,6 +179,12 @@ func GetRedisClient(addr string, pword string, database int) (*RedisClient, erro
 
 	logger.Log.Fatal().Err(err).Msg("Failed to connect to Redis after multiple attempts")
 
-	return nil, fmt.Errorf("failed to connect to Redis after %d attempts: %v", maxRetries, err)
+	return nil, fmt.Errorf("failed to connect to Redis after %d attempts: %v", policy.Attempts, err)
+
+}
 
+// HashTag wraps id in a Redis Cluster hash tag. Keys that contain the same tag, e.g. "{ABC123}" and
+// "validations:{ABC123}", are stored in the same slot and can be used together in transactions.
+func HashTag(id string) string {
+	return "{" + id + "}"
 }
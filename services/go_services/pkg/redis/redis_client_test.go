@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go_services/pkg/tlsconfig"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          Options
+		expectedType  interface{}
+		expectedError bool
+	}{
+		{name: "Standalone", opts: Options{Mode: ModeStandalone, Addrs: []string{"redis:6379"}, DB: 1}, expectedType: &redis.Client{}},
+		{name: "Default Mode", opts: Options{Addrs: []string{"redis:6379"}}, expectedType: &redis.Client{}},
+		{name: "Sentinel", opts: Options{Mode: ModeSentinel, Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "parking"}, expectedType: &redis.Client{}},
+		{name: "Cluster", opts: Options{Mode: ModeCluster, Addrs: []string{"n1:6379", "n2:6379"}}, expectedType: &redis.ClusterClient{}},
+		{name: "No Address", opts: Options{Mode: ModeStandalone}, expectedError: true},
+		{name: "Standalone With Several Addresses", opts: Options{Mode: ModeStandalone, Addrs: []string{"a:6379", "b:6379"}}, expectedError: true},
+		{name: "Sentinel Without Master", opts: Options{Mode: ModeSentinel, Addrs: []string{"s1:26379"}}, expectedError: true},
+		{name: "Cluster With Database", opts: Options{Mode: ModeCluster, Addrs: []string{"n1:6379"}, DB: 1}, expectedError: true},
+		{name: "Unknown Mode", opts: Options{Mode: "ring", Addrs: []string{"redis:6379"}}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.opts)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.IsType(t, tt.expectedType, client)
+			client.Close()
+		})
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      Settings
+		expected      Options
+		expectedTLS   bool
+		expectedError bool
+	}{
+		{
+			name:     "Sentinel",
+			settings: Settings{Mode: ModeSentinel, Address: "s1:26379, s2:26379", Username: "svc", Password: "secret", DB: 2, SentinelMaster: "parking", SentinelPassword: "sentinel"},
+			expected: Options{Mode: ModeSentinel, Addrs: []string{"s1:26379", "s2:26379"}, Username: "svc", Password: "secret", DB: 2, MasterName: "parking", SentinelPassword: "sentinel"},
+		},
+		{
+			name:     "TLS Files Without TLS",
+			settings: Settings{Address: "redis:6379", TLSFiles: tlsconfig.Files{CAFile: "/missing/ca.pem"}},
+			expected: Options{Addrs: []string{"redis:6379"}},
+		},
+		{
+			name:        "TLS",
+			settings:    Settings{Address: "redis:6379", TLS: true, TLSFiles: tlsconfig.Files{ServerName: "redis.internal"}},
+			expected:    Options{Addrs: []string{"redis:6379"}},
+			expectedTLS: true,
+		},
+		{
+			name:          "Missing CA File",
+			settings:      Settings{Address: "redis:6379", TLS: true, TLSFiles: tlsconfig.Files{CAFile: "/missing/ca.pem"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := NewOptions(tt.settings)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedTLS, opts.TLS != nil)
+			if opts.TLS != nil {
+				assert.Equal(t, tt.settings.TLSFiles.ServerName, opts.TLS.ServerName)
+			}
+			opts.TLS = nil
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+func TestParseAddrs(t *testing.T) {
+	assert.Equal(t, []string{"s1:26379", "s2:26379"}, ParseAddrs(" s1:26379, s2:26379,"))
+	assert.Nil(t, ParseAddrs(""))
+}
+
+func TestRedisClient_Cluster(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := NewClient(Options{Mode: ModeCluster, Addrs: []string{server.Addr()}})
+	assert.NoError(t, err)
+	r := &RedisClient{Client: client}
+	defer r.Client.Close()
+
+	entry := time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, r.AddFieldsToHash(HashTag("ABC123"), map[string]string{"entry_date_time": entry.Format(time.RFC3339)}))
+	assert.NoError(t, r.AddFieldsToHash("plate_registry", map[string]string{"ABC123": "permit"}))
+	assert.NoError(t, r.AppendToList("validations:"+HashTag("ABC123"), "v1"))
+
+	keys, err := r.ScanHashKeys(HashTag("*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"{ABC123}"}, keys)
+
+	closedEntry, err := r.CloseSession(HashTag("ABC123"), "entry_date_time", "exit_date_time", entry.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, entry, closedEntry)
+
+	items, err := r.GetListItems("validations:" + HashTag("ABC123"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, items)
+}
+
+func TestConnect_ACLUser(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireUserAuth("recordkeeper", "secret")
+
+	client, err := NewClient(Options{Addrs: []string{server.Addr()}, Username: "recordkeeper", Password: "wrong"})
+	assert.NoError(t, err)
+	assert.Error(t, client.Ping(context.Background()).Err())
+	client.Close()
+
+	r, err := Connect(Options{Addrs: []string{server.Addr()}, Username: "recordkeeper", Password: "secret"})
+	assert.NoError(t, err)
+	defer r.Client.Close()
+	assert.NoError(t, r.AddItemToSet("ABC123", "parked_vehicles"))
+}
@@ -2,7 +2,9 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"go_services/pkg/logger"
@@ -10,6 +12,12 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrFieldNotFound is returned when a requested hash field does not exist.
+var ErrFieldNotFound = errors.New("field does not exist")
+
+// ErrWriteFailed is wrapped by errors of writes that failed after the data they depend on was read.
+var ErrWriteFailed = errors.New("write failed")
+
 func (r *RedisClient) AddFieldToHash(hashKey string, fieldName string, fieldValue time.Time) error {
 	ctx := context.Background()
 	err := r.Client.HSet(ctx, hashKey, fieldName, fieldValue).Err()
@@ -21,6 +29,17 @@ func (r *RedisClient) AddFieldToHash(hashKey string, fieldName string, fieldValu
 	return nil
 }
 
+func (r *RedisClient) AddFieldsToHash(hashKey string, fields map[string]string) error {
+	ctx := context.Background()
+	err := r.Client.HSet(ctx, hashKey, fields).Err()
+	if err != nil {
+		logger.Log.Error().Err(err).Msgf("Error Setting Hash Fields for key %s", hashKey)
+		return err
+	}
+	logger.Log.Debug().Msgf(" %d fields added to Redis Hash for key %s", len(fields), hashKey)
+	return nil
+}
+
 func (r *RedisClient) GetFieldAsTime(hashKey string, fieldName string, layout string) (time.Time, error) {
 
 	ctx := context.Background()
@@ -28,7 +47,7 @@ func (r *RedisClient) GetFieldAsTime(hashKey string, fieldName string, layout st
 	if err != nil {
 		if err == redis.Nil {
 			// The field does not exist
-			return time.Time{}, fmt.Errorf("field %s does not exist in hash %s", fieldName, hashKey)
+			return time.Time{}, fmt.Errorf("%w: %s in hash %s", ErrFieldNotFound, fieldName, hashKey)
 		}
 		return time.Time{}, fmt.Errorf("failed to get field value: %v", err)
 	}
@@ -41,3 +60,55 @@ func (r *RedisClient) GetFieldAsTime(hashKey string, fieldName string, layout st
 
 	return parsedTime, nil
 }
+
+func (r *RedisClient) GetAllFields(hashKey string) (map[string]string, error) {
+	ctx := context.Background()
+	fields, err := r.Client.HGetAll(ctx, hashKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hash fields: %v", err)
+	}
+	return fields, nil
+}
+
+// ScanHashKeys returns the keys of all hashes matching the pattern, using SCAN so Redis is not blocked.
+// On a cluster every master is scanned.
+func (r *RedisClient) ScanHashKeys(pattern string) ([]string, error) {
+	ctx := context.Background()
+	cluster, ok := r.Client.(*redis.ClusterClient)
+	if !ok {
+		return scanHashKeys(ctx, r.Client, pattern)
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		masterKeys, err := scanHashKeys(ctx, master, pattern)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, masterKeys...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func scanHashKeys(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := client.ScanType(ctx, cursor, pattern, 100, "hash").Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hash keys: %v", err)
+		}
+		keys = append(keys, batch...)
+		cursor = next
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go_services/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func (r *RedisClient) AppendToList(listKey string, item string) error {
+	ctx := context.Background()
+	_, err := r.Client.RPush(ctx, listKey, item).Result()
+	if err != nil {
+		logger.Log.Error().Err(err).Msgf("Failed to append item to Redis List %s", listKey)
+		return err
+	}
+	logger.Log.Debug().Msgf("item appended to Redis List %s", listKey)
+	return nil
+}
+
+func (r *RedisClient) GetListItems(listKey string) ([]string, error) {
+	ctx := context.Background()
+	items, err := r.Client.LRange(ctx, listKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("could not read items from list %s: %w", listKey, err)
+	}
+	return items, nil
+}
+
+// TrimList keeps the last maxItems items of the list, dropping the older ones.
+func (r *RedisClient) TrimList(listKey string, maxItems int) error {
+	ctx := context.Background()
+	if err := r.Client.LTrim(ctx, listKey, int64(-maxItems), -1).Err(); err != nil {
+		return fmt.Errorf("could not trim list %s: %w", listKey, err)
+	}
+	return nil
+}
+
+func (r *RedisClient) DeleteKey(key string) error {
+	ctx := context.Background()
+	if err := r.Client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("could not delete key %s: %w", key, err)
+	}
+	return nil
+}
+
+// Increment atomically increments the integer stored at key and returns the new value.
+func (r *RedisClient) Increment(key string) (int64, error) {
+	ctx := context.Background()
+	value, err := r.Client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("could not increment %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// GetCounter returns the integer stored at key, 0 when the key does not exist.
+func (r *RedisClient) GetCounter(key string) (int64, error) {
+	ctx := context.Background()
+	value, err := r.Client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not read counter %s: %w", key, err)
+	}
+	return value, nil
+}
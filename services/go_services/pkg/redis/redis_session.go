@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_services/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxSessionTxRetries bounds the optimistic retries of CloseSession when the hash changes concurrently.
+const maxSessionTxRetries = 100
+
+// CloseSession atomically writes the exit time to exitField and returns the entry time in entryField
+// of the session that the exit closes. The read and the write run in one WATCH/MULTI/EXEC transaction,
+// so concurrent entries or exits on the same hash cannot pair the exit with the wrong entry. When the
+// hash has no entry the exit is still written; when its session was already closed by an exit after
+// the entry that exit is kept, and an exit older than the stored entry is ignored. In these
+// cases the returned error wraps ErrFieldNotFound; when writing the exit fails it wraps ErrWriteFailed.
+func (r *RedisClient) CloseSession(hashKey string, entryField string, exitField string, exitTime time.Time) (time.Time, error) {
+	ctx := context.Background()
+	var entryDateTime time.Time
+	var open bool
+
+	closeTx := func(tx *redis.Tx) error {
+		values, err := tx.HMGet(ctx, hashKey, entryField, exitField).Result()
+		if err != nil {
+			return err
+		}
+		entryDateTime, open, err = openEntry(values[0], values[1])
+		if err != nil {
+			return err
+		}
+		if open && exitTime.Before(entryDateTime) {
+			// The exit happened before the stored entry, so it belongs to an earlier session
+			open = false
+			return nil
+		}
+		if !open && values[0] != nil {
+			// Keep the exit that closed the session so it cannot be reopened by an older exit
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, hashKey, exitField, exitTime)
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("%w: %v", ErrWriteFailed, err)
+		}
+		return err
+	}
+
+	for retries := 0; retries < maxSessionTxRetries; retries++ {
+		err := r.Client.Watch(ctx, closeTx, hashKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			// The hash changed between the read and the write; read it again
+			continue
+		}
+		if err != nil {
+			logger.Log.Error().Err(err).Msgf("Error closing session for key %s", hashKey)
+			return time.Time{}, fmt.Errorf("failed to close session: %w", err)
+		}
+		if !open {
+			return time.Time{}, fmt.Errorf("%w: open %s in hash %s", ErrFieldNotFound, entryField, hashKey)
+		}
+		logger.Log.Debug().Msgf(" %s:%s closed session in Redis Hash for key %s", exitField, exitTime, hashKey)
+		return entryDateTime, nil
+	}
+
+	return time.Time{}, fmt.Errorf("failed to close session for key %s: too much contention", hashKey)
+}
+
+// openEntry parses the stored entry and exit values and reports whether they form an open session,
+// i.e. an entry without an exit or with an exit that is not after the entry. Such an exit is left over
+// from the previous session, possibly closed at the time of the new entry.
+func openEntry(entryValue interface{}, exitValue interface{}) (time.Time, bool, error) {
+	entry, ok := entryValue.(string)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	entryDateTime, err := time.Parse(time.RFC3339, entry)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse time: %v", err)
+	}
+
+	exit, ok := exitValue.(string)
+	if !ok {
+		return entryDateTime, true, nil
+	}
+	exitDateTime, err := time.Parse(time.RFC3339, exit)
+	if err != nil {
+		return entryDateTime, true, nil
+	}
+	return entryDateTime, !exitDateTime.After(entryDateTime), nil
+}
@@ -0,0 +1,169 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) *RedisClient {
+	server := miniredis.RunT(t)
+	return &RedisClient{Client: redis.NewClient(&redis.Options{Addr: server.Addr()})}
+}
+
+var (
+	sessionStart = time.Date(2024, 9, 11, 10, 0, 0, 0, time.UTC)
+	sessionExit  = sessionStart.Add(2 * time.Hour)
+)
+
+func TestCloseSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		fields        map[string]interface{}
+		expectedEntry time.Time
+		expectedExit  time.Time
+		expectedError error
+	}{
+		{
+			name:          "Open Session",
+			fields:        map[string]interface{}{"entry_date_time": sessionStart},
+			expectedEntry: sessionStart,
+			expectedExit:  sessionExit,
+		},
+		{
+			name: "Reentered After Previous Exit",
+			fields: map[string]interface{}{
+				"entry_date_time": sessionStart,
+				"exit_date_time":  sessionStart.Add(-time.Hour),
+			},
+			expectedEntry: sessionStart,
+			expectedExit:  sessionExit,
+		},
+		{
+			name:          "Exit Before Entry",
+			fields:        map[string]interface{}{"entry_date_time": sessionExit.Add(time.Minute)},
+			expectedError: ErrFieldNotFound,
+		},
+		{
+			name:          "No Entry",
+			fields:        map[string]interface{}{},
+			expectedExit:  sessionExit,
+			expectedError: ErrFieldNotFound,
+		},
+		{
+			name: "Already Closed",
+			fields: map[string]interface{}{
+				"entry_date_time": sessionStart,
+				"exit_date_time":  sessionStart.Add(time.Minute),
+			},
+			expectedExit:  sessionStart.Add(time.Minute),
+			expectedError: ErrFieldNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t)
+			if len(tt.fields) > 0 {
+				assert.NoError(t, client.Client.HSet(context.Background(), "ABC-123", tt.fields).Err())
+			}
+			entry, err := client.CloseSession("ABC-123", "entry_date_time", "exit_date_time", sessionExit)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, tt.expectedEntry.Equal(entry))
+			}
+			storedExit, err := client.GetFieldAsTime("ABC-123", "exit_date_time", time.RFC3339)
+			if tt.expectedExit.IsZero() {
+				assert.ErrorIs(t, err, ErrFieldNotFound)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.expectedExit.Equal(storedExit))
+		})
+	}
+}
+
+func TestCloseSession_ConcurrentExitsCloseOnce(t *testing.T) {
+	client := newTestClient(t)
+	assert.NoError(t, client.AddFieldToHash("ABC-123", "entry_date_time", sessionStart))
+
+	const exits = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	paired := 0
+	for i := 0; i < exits; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.CloseSession("ABC-123", "entry_date_time", "exit_date_time", sessionStart.Add(time.Duration(i+1)*time.Minute))
+			if err == nil {
+				mu.Lock()
+				paired++
+				mu.Unlock()
+				return
+			}
+			assert.True(t, errors.Is(err, ErrFieldNotFound), err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, paired)
+}
+
+func TestCloseSession_ConcurrentEntriesAndExits(t *testing.T) {
+	client := newTestClient(t)
+
+	// events are stamped from a shared clock when they are sent, so they interleave in time as well
+	var clock atomic.Int64
+	next := func() time.Time { return sessionStart.Add(time.Duration(clock.Add(1)) * time.Second) }
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			assert.NoError(t, client.AddFieldToHash("ABC-123", "entry_date_time", next()))
+		}
+	}()
+
+	var mu sync.Mutex
+	var pairedEntries [][2]time.Time
+	for exitSender := 0; exitSender < 2; exitSender++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				exitTime := next()
+				entry, err := client.CloseSession("ABC-123", "entry_date_time", "exit_date_time", exitTime)
+				if errors.Is(err, ErrFieldNotFound) {
+					continue
+				}
+				assert.NoError(t, err)
+				mu.Lock()
+				pairedEntries = append(pairedEntries, [2]time.Time{entry, exitTime})
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every entry is closed by at most one exit, and never by an exit from before it
+	assert.NotEmpty(t, pairedEntries)
+	seen := make(map[time.Time]bool)
+	for _, pair := range pairedEntries {
+		entry := pair[0]
+		assert.False(t, pair[1].Before(entry), "exit %v paired with later entry %v", pair[1], entry)
+		assert.False(t, seen[entry], "entry %v paired twice", entry)
+		seen[entry] = true
+	}
+}
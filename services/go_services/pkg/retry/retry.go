@@ -0,0 +1,29 @@
+// Package retry describes how often a failing operation is attempted and how long to wait in between.
+package retry
+
+import "time"
+
+// Policy makes up to Attempts attempts, waiting InitialBackoff after the first failure and twice as
+// long after each further one, up to MaxBackoff.
+type Policy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Or returns the policy, or fallback when no attempts are set.
+func (p Policy) Or(fallback Policy) Policy {
+	if p.Attempts <= 0 {
+		return fallback
+	}
+	return p
+}
+
+// Backoff returns the wait after the failed attempt, counted from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
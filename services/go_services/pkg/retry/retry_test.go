@@ -0,0 +1,34 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Backoff(t *testing.T) {
+	policy := Policy{Attempts: 5, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{attempt: 1, expected: 2 * time.Second},
+		{attempt: 2, expected: 4 * time.Second},
+		{attempt: 4, expected: 16 * time.Second},
+		{attempt: 5, expected: 30 * time.Second},
+		{attempt: 100, expected: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, policy.Backoff(tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestPolicy_Or(t *testing.T) {
+	fallback := Policy{Attempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+
+	assert.Equal(t, fallback, Policy{}.Or(fallback))
+	assert.Equal(t, Policy{Attempts: 1}, Policy{Attempts: 1}.Or(fallback))
+}
@@ -0,0 +1,61 @@
+// Package tlsconfig builds client TLS configurations from PEM files.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Files names the PEM files and server name of a client TLS configuration. All fields are optional:
+// without a CA file the system roots are trusted, and a client certificate is sent only when both the
+// certificate and key files are given.
+type Files struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// Load builds the client TLS configuration described by the files.
+func Load(files Files) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: files.ServerName,
+	}
+
+	if files.CAFile != "" {
+		pem, err := os.ReadFile(files.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", files.CAFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+	}
+	if files.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// LoadConfigured builds the client TLS configuration described by the files, or returns nil when none of
+// them is set, so that the client uses its defaults, e.g. the system roots for amqps:// connections.
+func LoadConfigured(files Files) (*tls.Config, error) {
+	if files == (Files{}) {
+		return nil, nil
+	}
+	return Load(files)
+}
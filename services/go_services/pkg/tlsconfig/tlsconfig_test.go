@@ -0,0 +1,92 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate writes a self-signed certificate and its key to dir and returns their paths.
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "parking-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir)
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, os.WriteFile(invalidFile, []byte("not a certificate"), 0600))
+
+	tests := []struct {
+		name          string
+		files         Files
+		expectedRoots bool
+		expectedCerts int
+		expectedError bool
+	}{
+		{name: "System Roots", files: Files{ServerName: "redis.internal"}},
+		{name: "Custom CA", files: Files{CAFile: certFile}, expectedRoots: true},
+		{name: "Client Certificate", files: Files{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, expectedRoots: true, expectedCerts: 1},
+		{name: "Missing CA File", files: Files{CAFile: filepath.Join(dir, "missing.pem")}, expectedError: true},
+		{name: "Invalid CA File", files: Files{CAFile: invalidFile}, expectedError: true},
+		{name: "Certificate Without Key", files: Files{CertFile: certFile}, expectedError: true},
+		{name: "Invalid Key", files: Files{CertFile: certFile, KeyFile: invalidFile}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.files)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.files.ServerName, cfg.ServerName)
+			assert.Equal(t, tt.expectedRoots, cfg.RootCAs != nil)
+			assert.Len(t, cfg.Certificates, tt.expectedCerts)
+		})
+	}
+}
+
+func TestLoadConfigured(t *testing.T) {
+	cfg, err := LoadConfigured(Files{})
+	assert.NoError(t, err)
+	assert.Nil(t, cfg)
+
+	cfg, err = LoadConfigured(Files{ServerName: "rabbitmq.internal"})
+	assert.NoError(t, err)
+	assert.Equal(t, "rabbitmq.internal", cfg.ServerName)
+
+	_, err = LoadConfigured(Files{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+}
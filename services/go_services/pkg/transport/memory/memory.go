@@ -0,0 +1,198 @@
+// Package memory is an in-process transport for tests that run the services without a broker. Each
+// destination is a queue, unless queues are bound to it like to a topic exchange: the events are handed
+// to the queue's consumer in publishing order, by the publishing goroutine before Publish returns, so
+// tests observe the processed events deterministically.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"go_services/pkg/logger"
+	"go_services/pkg/transport"
+)
+
+var (
+	_ transport.Publisher = (*Broker)(nil)
+	_ transport.Consumer  = (*Broker)(nil)
+)
+
+// Message is a published event.
+type Message struct {
+	Key  string
+	Type string
+	Body []byte
+}
+
+// queue keeps the events of a destination until they are handed to its consumer.
+type queue struct {
+	published  []Message
+	pending    []Message
+	handle     func(msg Message) error // nil until the queue is consumed
+	delivering bool
+}
+
+// binding routes the events of the routing keys matching pattern to a queue.
+type binding struct {
+	queue   string
+	pattern string
+}
+
+// Broker publishes and consumes events in memory; the zero value is ready to use.
+type Broker struct {
+	mu       sync.Mutex
+	queues   map[string]*queue
+	bindings []binding
+}
+
+// NewBroker creates an empty broker.
+func NewBroker() *Broker {
+	return &Broker{}
+}
+
+func (b *Broker) queue(name string) *queue {
+	if b.queues == nil {
+		b.queues = make(map[string]*queue)
+	}
+	q, ok := b.queues[name]
+	if !ok {
+		q = &queue{}
+		b.queues[name] = q
+	}
+	return q
+}
+
+// Bind routes the events published to routing keys matching the pattern to the queue, like a binding
+// to a topic exchange: '*' matches one word of the key and '#' any number of words.
+func (b *Broker) Bind(queue, pattern string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bindings = append(b.bindings, binding{queue: queue, pattern: pattern})
+}
+
+// Publish publishes the event as JSON to the queues bound to the destination, or without a matching
+// binding to the queue named by the destination, and hands it to the consumers of the consumed ones.
+// Events of a queue without consumer are kept until it is consumed.
+func (b *Broker) Publish(destination, key, eventType string, eventPayload any) error {
+	body, err := json.Marshal(eventPayload)
+	if err != nil {
+		logger.Log.Error().Err(err).Msgf("JSON conversion error in %v...", eventPayload)
+		return err
+	}
+
+	msg := Message{Key: key, Type: eventType, Body: body}
+	b.mu.Lock()
+	b.queue(destination).published = append(b.queue(destination).published, msg)
+	targets := b.routes(destination)
+	for _, name := range targets {
+		q := b.queue(name)
+		if name != destination {
+			q.published = append(q.published, msg)
+		}
+		q.pending = append(q.pending, msg)
+	}
+	b.mu.Unlock()
+
+	for _, name := range targets {
+		b.deliver(name)
+	}
+	return nil
+}
+
+// routes returns the queues bound to the routing key, or the queue it names when none is.
+func (b *Broker) routes(routingKey string) []string {
+	var queues []string
+	for _, bound := range b.bindings {
+		if topicMatch(strings.Split(bound.pattern, "."), strings.Split(routingKey, ".")) && !slices.Contains(queues, bound.queue) {
+			queues = append(queues, bound.queue)
+		}
+	}
+	if len(queues) == 0 {
+		return []string{routingKey}
+	}
+	return queues
+}
+
+// topicMatch reports whether the words of a routing key match the words of a binding pattern.
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for skip := 0; skip <= len(words); skip++ {
+			if topicMatch(pattern[1:], words[skip:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && words[0] == pattern[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
+
+// Consume hands the events of the queue to the handler.
+func (b *Broker) Consume(source string, handler transport.Handler) error {
+	return b.consume(source, func(msg Message) error {
+		return handler.ProcessMessage(msg.Body)
+	})
+}
+
+// ConsumeTyped hands each event of the queue to the handler of its type.
+func (b *Broker) ConsumeTyped(source string, handlers map[string]transport.Handler) error {
+	return b.consume(source, func(msg Message) error {
+		return transport.Dispatch(handlers, msg.Type, msg.Body)
+	})
+}
+
+func (b *Broker) consume(source string, handle func(msg Message) error) error {
+	b.mu.Lock()
+	q := b.queue(source)
+	if q.handle != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("queue %s is already consumed", source)
+	}
+	q.handle = handle
+	b.mu.Unlock()
+
+	b.deliver(source)
+	return nil
+}
+
+// deliver hands the pending events of the queue to its consumer one at a time. Events published while
+// the consumer runs, e.g. by a handler publishing to the queue it consumes, are delivered by the call
+// already delivering, so the order is kept without blocking the handler.
+func (b *Broker) deliver(name string) {
+	b.mu.Lock()
+	q := b.queue(name)
+	if q.handle == nil || q.delivering {
+		b.mu.Unlock()
+		return
+	}
+	q.delivering = true
+	for len(q.pending) > 0 {
+		msg := q.pending[0]
+		q.pending = q.pending[1:]
+		b.mu.Unlock()
+
+		if err := q.handle(msg); err != nil {
+			logger.Log.Error().Err(err).Msg("Failed to process consumed message body ")
+		}
+
+		b.mu.Lock()
+	}
+	q.delivering = false
+	b.mu.Unlock()
+}
+
+// Published returns the events published to the destination or routed to it, consumed or not.
+func (b *Broker) Published(destination string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.queue(destination).published...)
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"go_services/pkg/transport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type handlerFunc func(msg []byte) error
+
+func (f handlerFunc) ProcessMessage(msg []byte) error { return f(msg) }
+
+func TestBroker_DeliversInOrder(t *testing.T) {
+	broker := NewBroker()
+	var processed []string
+	record := func(eventType string) transport.Handler {
+		return handlerFunc(func(msg []byte) error {
+			processed = append(processed, eventType+" "+string(msg))
+			return nil
+		})
+	}
+
+	// events published before the queue is consumed are kept
+	assert.NoError(t, broker.Publish("vehicle_events", "ABC123", transport.EntryEvent, "first"))
+	assert.NoError(t, broker.ConsumeTyped("vehicle_events", map[string]transport.Handler{
+		transport.EntryEvent: record(transport.EntryEvent),
+		transport.ExitEvent:  record(transport.ExitEvent),
+	}))
+	assert.Equal(t, []string{`entry "first"`}, processed)
+
+	// later events are processed before Publish returns; unknown types are dropped
+	assert.NoError(t, broker.Publish("vehicle_events", "ABC123", transport.ExitEvent, "second"))
+	assert.NoError(t, broker.Publish("vehicle_events", "ABC123", "validation", "third"))
+	assert.Equal(t, []string{`entry "first"`, `exit "second"`}, processed)
+	assert.Len(t, broker.Published("vehicle_events"), 3)
+
+	assert.Error(t, broker.Consume("vehicle_events", record(transport.EntryEvent)))
+	assert.Error(t, broker.Publish("vehicle_events", "ABC123", transport.EntryEvent, func() {}))
+}
+
+func TestBroker_PublishFromHandler(t *testing.T) {
+	broker := NewBroker()
+	var processed []string
+	assert.NoError(t, broker.Consume("vehicle_entries", handlerFunc(func(msg []byte) error {
+		processed = append(processed, string(msg))
+		if string(msg) == `"first"` {
+			// delivered after the current event, by the publish that is already delivering
+			assert.NoError(t, broker.Publish("vehicle_entries", "", "", "retry"))
+			assert.Equal(t, []string{`"first"`}, processed)
+		}
+		return nil
+	})))
+
+	assert.NoError(t, broker.Publish("vehicle_entries", "", "", "first"))
+	assert.NoError(t, broker.Publish("vehicle_entries", "", "", "second"))
+	assert.Equal(t, []string{`"first"`, `"retry"`, `"second"`}, processed)
+
+	published := broker.Published("vehicle_entries")
+	assert.Equal(t, Message{Body: []byte(`"second"`)}, published[2])
+	assert.Empty(t, broker.Published("parking_alerts"))
+}
+
+func TestBroker_Bind(t *testing.T) {
+	broker := NewBroker()
+	broker.Bind("vehicle_entries", "parking.*.entry")
+	broker.Bind("north_events", "parking.north.#")
+	broker.Bind("north_events", "parking.*.exit")
+
+	assert.NoError(t, broker.Publish("parking.north.entry", "ABC123", transport.EntryEvent, "1"))
+	assert.NoError(t, broker.Publish("parking.south.entry", "ABC123", transport.EntryEvent, "2"))
+	assert.NoError(t, broker.Publish("parking.north.exit", "ABC123", transport.ExitEvent, "3"))
+	assert.NoError(t, broker.Publish("vehicle_entries", "ABC123", transport.EntryEvent, "4"))
+
+	bodies := func(messages []Message) []string {
+		var values []string
+		for _, msg := range messages {
+			values = append(values, string(msg.Body))
+		}
+		return values
+	}
+	assert.Equal(t, []string{`"1"`, `"2"`, `"4"`}, bodies(broker.Published("vehicle_entries")))
+	assert.Equal(t, []string{`"1"`, `"3"`}, bodies(broker.Published("north_events")), "delivered once per queue")
+	assert.Equal(t, []string{`"3"`}, bodies(broker.Published("parking.north.exit")))
+
+	var processed []string
+	assert.NoError(t, broker.Consume("north_events", handlerFunc(func(msg []byte) error {
+		processed = append(processed, string(msg))
+		return nil
+	})))
+	assert.Equal(t, []string{`"1"`, `"3"`}, processed)
+}
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		key      string
+		expected bool
+	}{
+		{"parking.*.entry", "parking.north.entry", true},
+		{"parking.*.entry", "parking.north.exit", false},
+		{"parking.*.entry", "parking.entry", false},
+		{"parking.#", "parking", true},
+		{"parking.#", "parking.north.exit", true},
+		{"#.exit", "parking.north.exit", true},
+		{"parking.north.exit", "parking.north.exit", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, topicMatch(strings.Split(tt.pattern, "."), strings.Split(tt.key, ".")), "%s %s", tt.pattern, tt.key)
+	}
+}
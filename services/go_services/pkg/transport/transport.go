@@ -0,0 +1,42 @@
+// Package transport defines the broker-neutral interfaces the services publish and consume events
+// through; pkg/rabbitmq and pkg/kafka implement them.
+package transport
+
+import "fmt"
+
+// Event types, sent as message type so that consumers of shared queues and topics dispatch them
+const (
+	EntryEvent = "entry"
+	ExitEvent  = "exit"
+)
+
+// Handler processes the body of a consumed message.
+type Handler interface {
+	ProcessMessage(msg []byte) error
+}
+
+// Publisher publishes events as JSON.
+type Publisher interface {
+	// Publish publishes the event of the type to the destination, a RabbitMQ routing key or queue or a
+	// Kafka topic. The key, e.g. the vehicle plate, keeps the events with the same key in order where the
+	// broker partitions them.
+	Publish(destination, key, eventType string, eventPayload any) error
+}
+
+// Consumer consumes events.
+type Consumer interface {
+	// Consume hands the messages of the source, a queue or topic, to the handler.
+	Consume(source string, handler Handler) error
+	// ConsumeTyped hands each message of the source to the handler of its type, so that events of
+	// different types keep their order.
+	ConsumeTyped(source string, handlers map[string]Handler) error
+}
+
+// Dispatch hands the message body to the handler of its type.
+func Dispatch(handlers map[string]Handler, messageType string, body []byte) error {
+	handler, ok := handlers[messageType]
+	if !ok {
+		return fmt.Errorf("no handler for message type %q", messageType)
+	}
+	return handler.ProcessMessage(body)
+}